@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/caarlos0/env"
+	"github.com/nickzhog/gophermart/internal/accrualmock"
+	"github.com/nickzhog/gophermart/internal/web"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/money"
+)
+
+type settings struct {
+	RunAddress      string        `env:"RUN_ADDRESS"`
+	Rewards         string        `env:"ACCRUAL_MOCK_REWARDS"`
+	DefaultReward   string        `env:"ACCRUAL_MOCK_DEFAULT_REWARD"`
+	InvalidRate     float64       `env:"ACCRUAL_MOCK_INVALID_RATE"`
+	ProcessingDelay time.Duration `env:"ACCRUAL_MOCK_PROCESSING_DELAY"`
+	RateLimit       int           `env:"ACCRUAL_MOCK_RATE_LIMIT"`
+}
+
+func main() {
+	logger := logging.GetLogger()
+
+	var s settings
+	flag.StringVar(&s.RunAddress, "a", ":8081", "address for server listen")
+	flag.StringVar(&s.Rewards, "rewards", "", "reward per order number prefix, e.g. 2377=100,5880=500.5")
+	flag.StringVar(&s.DefaultReward, "default-reward", "10", "reward for orders matching no prefix")
+	flag.Float64Var(&s.InvalidRate, "invalid-rate", 0, "fraction of orders answered as INVALID")
+	flag.DurationVar(&s.ProcessingDelay, "processing-delay", time.Second*2, "time until an order gets its final status")
+	flag.IntVar(&s.RateLimit, "rate-limit", 0, "requests per minute before answering 429, 0 disables the limit")
+	flag.Parse()
+	env.Parse(&s)
+	logger.Tracef("%+v", s)
+
+	rewards, err := accrualmock.ParseRewards(s.Rewards)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defaultReward, err := money.Parse(s.DefaultReward)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	mock := accrualmock.NewServer(accrualmock.Rules{
+		Rewards:         rewards,
+		DefaultReward:   defaultReward,
+		InvalidRate:     s.InvalidRate,
+		ProcessingDelay: s.ProcessingDelay,
+		RateLimit:       s.RateLimit,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		oscall := <-c
+		logger.Tracef("system call:%+v", oscall)
+		cancel()
+	}()
+
+	srv := &http.Server{
+		Addr:    s.RunAddress,
+		Handler: mock.Handler(),
+	}
+	if err := web.Serve(ctx, logger, srv); err != nil {
+		logger.Errorf("failed to serve: %s", err.Error())
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+)
+
+// maxLineLen ограничивает длину строки при проверке файла
+const maxLineLen = 1 << 20
+
+func runLuhn(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "gen":
+		return luhnGen(args[1:], stdout, stderr)
+	case "check":
+		return luhnCheck(args[1:], stdout, stderr)
+	case "file-check":
+		return luhnFileCheck(args[1:], stdin, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown luhn command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
+
+// luhnGen печатает count разных номеров длины length, начинающихся с prefix
+func luhnGen(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("luhn gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	prefix := fs.String("prefix", "", "digits every number starts with")
+	length := fs.Int("length", 16, "number length including the check digit")
+	count := fs.Int("count", 1, "how many numbers to generate")
+	seed := fs.Int64("seed", 0, "random seed, 0 means current time")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	numbers, err := generateNumbers(rand.New(rand.NewSource(*seed)), *prefix, *length, *count)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+	for _, n := range numbers {
+		fmt.Fprintln(w, n)
+	}
+	return exitOK
+}
+
+// generateNumbers возвращает count разных корректных номеров.
+// Случайные цифры ставятся между prefix и контрольной цифрой
+func generateNumbers(r *rand.Rand, prefix string, length, count int) ([]string, error) {
+	if strings.Trim(prefix, "0123456789") != "" {
+		return nil, fmt.Errorf("prefix must contain only digits: %q", prefix)
+	}
+	if count < 1 {
+		return nil, errors.New("count must be positive")
+	}
+	free := length - len(prefix) - 1
+	if free < 0 {
+		return nil, fmt.Errorf("length must be greater than prefix length %d", len(prefix))
+	}
+	// вариантов 10^free, дальше 18 цифр считать не нужно
+	variants := 1
+	for i := 0; i < free && i < 18; i++ {
+		variants *= 10
+	}
+	if strings.Trim(prefix, "0") == "" {
+		// номер из одних нулей некорректен
+		variants--
+	}
+	if count > variants {
+		return nil, fmt.Errorf("only %d numbers of length %d start with %q", variants, length, prefix)
+	}
+
+	numbers := make([]string, 0, count)
+	seen := make(map[string]struct{}, count)
+	body := make([]byte, free)
+	for len(numbers) < count {
+		for i := range body {
+			body[i] = byte('0' + r.Intn(10))
+		}
+		number := prefix + string(body)
+		d, err := order.CalculateLuhn(number)
+		if err != nil {
+			return nil, err
+		}
+		number += strconv.Itoa(d)
+		if _, err = order.ParseNumber(number); err != nil {
+			// номер из одних нулей
+			continue
+		}
+		if _, ok := seen[number]; ok {
+			continue
+		}
+		seen[number] = struct{}{}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// luhnCheck проверяет номера из аргументов
+func luhnCheck(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	code := exitOK
+	for _, n := range args {
+		if _, err := order.ParseNumber(n); err != nil {
+			fmt.Fprintf(stdout, "%s\tinvalid: %s\n", n, reason(err))
+			code = exitInvalid
+			continue
+		}
+		fmt.Fprintf(stdout, "%s\tvalid\n", n)
+	}
+	return code
+}
+
+// luhnFileCheck проверяет номера из файла, по одному в строке, и печатает только некорректные.
+// Пустые строки пропускаются. Без аргумента или с "-" номера читаются из stdin
+func luhnFileCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 1 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	in, name := stdin, "stdin"
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		defer f.Close()
+		in, name = f, args[0]
+	}
+
+	w := bufio.NewWriter(stdout)
+
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineLen)
+	var checked, invalid int
+	for line := 1; sc.Scan(); line++ {
+		n := sc.Text()
+		if strings.TrimSpace(n) == "" {
+			continue
+		}
+		checked++
+		if _, err := order.ParseNumber(n); err != nil {
+			invalid++
+			fmt.Fprintf(w, "%s:%d: %q: %s\n", name, line, n, reason(err))
+		}
+	}
+	w.Flush()
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", name, err)
+		return exitUsage
+	}
+
+	fmt.Fprintf(stderr, "checked %d numbers, %d invalid\n", checked, invalid)
+	if invalid > 0 {
+		return exitInvalid
+	}
+	return exitOK
+}
+
+// reason возвращает причину без общего префикса ошибки
+func reason(err error) string {
+	return strings.TrimPrefix(err.Error(), order.ErrInvalidNumber.Error()+": ")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		length  int
+		count   int
+		wantErr bool
+	}{
+		{name: "with prefix", prefix: "2377", length: 16, count: 100},
+		{name: "longer than int64", prefix: "5880", length: 40, count: 10},
+		{name: "all variants", prefix: "12", length: 4, count: 10},
+		{name: "all variants without zero", prefix: "0", length: 3, count: 9},
+		{name: "too many", prefix: "12", length: 4, count: 11, wantErr: true},
+		{name: "prefix too long", prefix: "2377", length: 4, count: 1, wantErr: true},
+		{name: "prefix not digits", prefix: "23a", length: 16, count: 1, wantErr: true},
+		{name: "zero count", length: 16, count: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			numbers, err := generateNumbers(rand.New(rand.NewSource(1)), tt.prefix, tt.length, tt.count)
+			assert.Equal(tt.wantErr, err != nil)
+			if err != nil {
+				return
+			}
+			assert.Len(numbers, tt.count)
+			seen := make(map[string]bool)
+			for _, n := range numbers {
+				assert.Len(n, tt.length)
+				assert.True(strings.HasPrefix(n, tt.prefix))
+				_, err := order.ParseNumber(n)
+				assert.NoError(err, n)
+				assert.False(seen[n], "duplicate %s", n)
+				seen[n] = true
+			}
+		})
+	}
+}
+
+func TestLuhnCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+	}{
+		{
+			name:       "check valid",
+			args:       []string{"luhn", "check", "79927398713"},
+			wantCode:   exitOK,
+			wantStdout: "79927398713\tvalid\n",
+		},
+		{
+			name:       "check invalid",
+			args:       []string{"luhn", "check", "79927398713", "7992739871x"},
+			wantCode:   exitInvalid,
+			wantStdout: "79927398713\tvalid\n7992739871x\tinvalid: number must contain only digits\n",
+		},
+		{
+			name:       "file check",
+			args:       []string{"luhn", "file-check"},
+			stdin:      "5880182\n\n5880183\n 71476808630764 \n",
+			wantCode:   exitInvalid,
+			wantStdout: "stdin:3: \"5880183\": luhn check fail\n",
+		},
+		{
+			name:     "file check all valid",
+			args:     []string{"luhn", "file-check", "-"},
+			stdin:    "5880182\n71476808630764\n",
+			wantCode: exitOK,
+		},
+		{
+			name:     "missing file",
+			args:     []string{"luhn", "file-check", "/nonexistent/numbers.txt"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"luhn", "fix"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "bad gen flags",
+			args:     []string{"luhn", "gen", "--length", "x"},
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			assert.Equal(tt.wantCode, code, stderr.String())
+			assert.Equal(tt.wantStdout, stdout.String())
+		})
+	}
+}
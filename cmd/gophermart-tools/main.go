@@ -0,0 +1,44 @@
+// gophermart-tools - вспомогательные команды для разработки и тестирования gophermart
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: gophermart-tools <command> [arguments]
+
+commands:
+  luhn gen [-prefix digits] [-length n] [-count n]  generate valid order numbers
+  luhn check number...                              check order numbers
+  luhn file-check [file]                            check order numbers from file or stdin, one per line
+`
+
+// коды завершения
+const (
+	exitOK      = 0
+	exitInvalid = 1 // найдены некорректные номера
+	exitUsage   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "luhn":
+		return runLuhn(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
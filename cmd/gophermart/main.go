@@ -6,11 +6,16 @@ import (
 	"os/signal"
 	"sync"
 
+	"github.com/nickzhog/gophermart/internal/accrual"
 	"github.com/nickzhog/gophermart/internal/config"
 	"github.com/nickzhog/gophermart/internal/migration"
+	"github.com/nickzhog/gophermart/internal/orderevents"
 	"github.com/nickzhog/gophermart/internal/orderprocesser"
 	"github.com/nickzhog/gophermart/internal/repositories"
+	"github.com/nickzhog/gophermart/internal/service/loginattempt"
 	"github.com/nickzhog/gophermart/internal/web"
+	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/internal/webhooksender"
 	"github.com/nickzhog/gophermart/pkg/logging"
 )
 
@@ -18,6 +23,9 @@ func main() {
 	logger := logging.GetLogger()
 	cfg := config.GetConfig()
 	logger.Tracef("%+v", cfg.Settings)
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal(err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -35,12 +43,29 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	reps := repositories.GetRepositories(ctx, logger, cfg)
+	pool := repositories.Connect(ctx, logger, cfg)
+	reps := repositories.New(logger, cfg, pool)
 
 	wg := new(sync.WaitGroup)
-	wg.Add(2)
+
+	// события заказов: в одном экземпляре брокер получает их напрямую,
+	// в нескольких - через LISTEN/NOTIFY, включая события самого экземпляра
+	broker := orderevents.NewBroker(logger)
+	var events orderevents.Publisher = broker
+	if cfg.Settings.OrderEventsNotify {
+		events = orderevents.NewNotifier(pool)
+
+		wg.Add(1)
+		go func() {
+			orderevents.Listen(ctx, logger, pool, broker)
+			wg.Done()
+		}()
+	}
+
+	wg.Add(5)
 	go func() {
-		err := orderprocesser.NewProcesser(logger, cfg, reps.Order).StartScan(ctx)
+		accrualClient := accrual.NewClient(cfg)
+		err := orderprocesser.NewProcesser(logger, cfg, reps.Order, reps.Ledger, accrualClient, events).StartScan(ctx)
 		if err != nil {
 			logger.Errorf("order processer error: %s", err.Error())
 		}
@@ -48,7 +73,25 @@ func main() {
 	}()
 
 	go func() {
-		srv := web.PrepareServer(logger, cfg, reps)
+		err := webhooksender.NewSender(logger, cfg, reps.Webhook).StartDelivery(ctx)
+		if err != nil {
+			logger.Errorf("webhook sender error: %s", err.Error())
+		}
+		wg.Done()
+	}()
+
+	go func() {
+		session.StartPurge(ctx, logger, reps.Session, cfg.Settings.SessionPurgeInterval)
+		wg.Done()
+	}()
+
+	go func() {
+		loginattempt.StartPurge(ctx, logger, reps.LoginAttempt, cfg.Settings.LoginFailureWindow)
+		wg.Done()
+	}()
+
+	go func() {
+		srv := web.PrepareServer(logger, cfg, reps, broker)
 		if err := web.Serve(ctx, logger, srv); err != nil {
 			logger.Errorf("failed to serve: %s", err.Error())
 		}
@@ -0,0 +1,54 @@
+package accrual
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/pkg/money"
+)
+
+// статусы расчёта начисления в системе начислений
+const (
+	StatusRegistered = "REGISTERED" // заказ зарегистрирован, но начисление не рассчитано
+	StatusInvalid    = "INVALID"    // заказ не принят к расчёту, и вознаграждение не будет начислено
+	StatusProcessing = "PROCESSING" // расчёт начисления в процессе
+	StatusProcessed  = "PROCESSED"  // расчёт начисления окончен
+)
+
+var (
+	ErrNotRegistered      = errors.New("order is not registered in accrual system")
+	ErrServer             = errors.New("accrual system internal error")
+	ErrUnexpectedStatus   = errors.New("unexpected accrual system response status")
+	ErrUnexpectedResponse = errors.New("unexpected accrual system response")
+)
+
+// RateLimitError - ответ 429 от системы начислений
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Limit      int // запросов в минуту, 0 - не объявлено
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many requests: retry after %s, limit %d per minute", e.RetryAfter, e.Limit)
+}
+
+type Answer struct {
+	Order   string       `json:"order"`
+	Status  string       `json:"status"`
+	Accrual money.Amount `json:"accrual,omitempty"`
+}
+
+// OrderStatus переводит статус системы начислений в статус заказа
+func (a Answer) OrderStatus() (string, error) {
+	switch a.Status {
+	case StatusRegistered, StatusProcessing:
+		return order.StatusProcessing, nil
+	case StatusInvalid:
+		return order.StatusInvalid, nil
+	case StatusProcessed:
+		return order.StatusProcessed, nil
+	}
+	return "", fmt.Errorf("%w: status %q", ErrUnexpectedResponse, a.Status)
+}
@@ -0,0 +1,149 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/config"
+)
+
+type Client interface {
+	// GetOrder возвращает расчёт начисления по заказу.
+	// Все вызовы ограничиваются общим лимитом, объявленным системой начислений в ответе 429.
+	GetOrder(ctx context.Context, number string) (Answer, error)
+	// Delay оценивает, через сколько общий лимит пропустит n запросов,
+	// с учётом паузы после ответа 429
+	Delay(n int) time.Duration
+}
+
+type client struct {
+	address    string
+	httpClient *http.Client
+	limiter    *limiter
+}
+
+type Option func(*client)
+
+// WithHTTPClient заменяет HTTP-клиент, например для подмены транспорта
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *client) {
+		cl.httpClient = c
+	}
+}
+
+func NewClient(cfg *config.Config, opts ...Option) Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.Settings.AccrualWorkers
+
+	c := &client{
+		address: strings.TrimRight(cfg.Settings.AccrualSystemAddress, "/"),
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Settings.AccrualRequestTimeout,
+		},
+		limiter: newLimiter(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *client) Delay(n int) time.Duration {
+	return c.limiter.delay(n, time.Now())
+}
+
+func (c *client) GetOrder(ctx context.Context, number string) (Answer, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return Answer{}, err
+	}
+
+	fullURL := fmt.Sprintf("%s/api/orders/%s", c.address, url.PathEscape(number))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return Answer{}, err
+	}
+	res, err := c.httpClient.Do(request)
+	if err != nil {
+		return Answer{}, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return Answer{}, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return Answer{}, ErrNotRegistered
+	case http.StatusTooManyRequests:
+		now := time.Now()
+		rateErr := &RateLimitError{
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), now),
+			Limit:      parseRateLimit(string(body)),
+		}
+		c.limiter.Pause(now.Add(rateErr.RetryAfter))
+		c.limiter.SetLimit(rateErr.Limit)
+		return Answer{}, rateErr
+	case http.StatusInternalServerError:
+		return Answer{}, ErrServer
+	default:
+		return Answer{}, fmt.Errorf("%w: %d", ErrUnexpectedStatus, res.StatusCode)
+	}
+
+	var ans Answer
+	if err = json.Unmarshal(body, &ans); err != nil {
+		return Answer{}, fmt.Errorf("%w: url:%s, body:%s, err:%s",
+			ErrUnexpectedResponse, fullURL, string(body), err.Error())
+	}
+	if ans.Order != number {
+		return Answer{}, fmt.Errorf("%w: order %q instead of %q", ErrUnexpectedResponse, ans.Order, number)
+	}
+	if _, err = ans.OrderStatus(); err != nil {
+		return Answer{}, err
+	}
+
+	return ans, nil
+}
+
+// defaultRetryAfter - пауза, если система начислений не указала Retry-After
+const defaultRetryAfter = time.Minute
+
+var rateLimitRe = regexp.MustCompile(`No more than (\d+) requests per minute`)
+
+// parseRetryAfter разбирает заголовок Retry-After в секундах или в формате HTTP-date
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if secs, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
+
+// parseRateLimit извлекает N из ответа "No more than N requests per minute allowed"
+func parseRateLimit(body string) int {
+	m := rateLimitRe.FindStringSubmatch(body)
+	if m == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,256 @@
+package accrual
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	processedOrderID     = "5880182"
+	processingOrderID    = "71476808630764"
+	registeredOrderID    = "485542211"
+	notRegisteredOrderID = "12345678903"
+	brokenOrderID        = "2377225624"
+	rateLimitedOrderID   = "9278923470"
+	unknownStatusOrderID = "346436439"
+	rateLimitRetryAfter  = "1"
+)
+
+// newAccrualStub - подмена системы начислений, отвечающая по номеру заказа
+func newAccrualStub() (*httptest.Server, *int32) {
+	calls := new(int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		number := r.URL.Path[len("/api/orders/"):]
+		switch number {
+		case processedOrderID:
+			fmt.Fprintf(w, `{"order":"%s","status":"PROCESSED","accrual":500.5}`, number)
+		case processingOrderID:
+			fmt.Fprintf(w, `{"order":"%s","status":"PROCESSING"}`, number)
+		case registeredOrderID:
+			fmt.Fprintf(w, `{"order":"%s","status":"REGISTERED"}`, number)
+		case unknownStatusOrderID:
+			fmt.Fprintf(w, `{"order":"%s","status":"UNKNOWN"}`, number)
+		case notRegisteredOrderID:
+			w.WriteHeader(http.StatusNoContent)
+		case rateLimitedOrderID:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Retry-After", rateLimitRetryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, "No more than 120 requests per minute allowed")
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	return srv, calls
+}
+
+func prepareClient(url string) *client {
+	cfg := &config.Config{}
+	cfg.Settings.AccrualSystemAddress = url
+	cfg.Settings.AccrualRequestTimeout = time.Second
+	cfg.Settings.AccrualWorkers = 2
+	return NewClient(cfg).(*client)
+}
+
+func Test_client_GetOrder(t *testing.T) {
+	srv, _ := newAccrualStub()
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		number     string
+		want       Answer
+		wantStatus string
+		wantErr    error
+	}{
+		{
+			name:       "processed",
+			number:     processedOrderID,
+			want:       Answer{Order: processedOrderID, Status: StatusProcessed, Accrual: 50050},
+			wantStatus: order.StatusProcessed,
+		},
+		{
+			name:       "processing",
+			number:     processingOrderID,
+			want:       Answer{Order: processingOrderID, Status: StatusProcessing},
+			wantStatus: order.StatusProcessing,
+		},
+		{
+			name:       "registered",
+			number:     registeredOrderID,
+			want:       Answer{Order: registeredOrderID, Status: StatusRegistered},
+			wantStatus: order.StatusProcessing,
+		},
+		{
+			name:    "not registered",
+			number:  notRegisteredOrderID,
+			wantErr: ErrNotRegistered,
+		},
+		{
+			name:    "unknown status",
+			number:  unknownStatusOrderID,
+			wantErr: ErrUnexpectedResponse,
+		},
+		{
+			name:    "internal error",
+			number:  brokenOrderID,
+			wantErr: ErrServer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			c := prepareClient(srv.URL)
+
+			got, err := c.GetOrder(context.Background(), tt.number)
+			if tt.wantErr != nil {
+				assert.ErrorIs(err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(tt.want, got)
+
+			status, err := got.OrderStatus()
+			assert.NoError(err)
+			assert.Equal(tt.wantStatus, status)
+		})
+	}
+}
+
+func Test_client_GetOrderRateLimited(t *testing.T) {
+	assert := assert.New(t)
+	srv, calls := newAccrualStub()
+	defer srv.Close()
+	c := prepareClient(srv.URL)
+
+	_, err := c.GetOrder(context.Background(), rateLimitedOrderID)
+	var rateErr *RateLimitError
+	require.ErrorAs(t, err, &rateErr)
+	assert.Equal(time.Second, rateErr.RetryAfter)
+	assert.Equal(120, rateErr.Limit)
+	assert.Equal(120, c.limiter.Limit())
+
+	// пока не прошло Retry-After, запросы не уходят в систему начислений
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, err = c.GetOrder(ctx, processedOrderID)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.EqualValues(1, atomic.LoadInt32(calls))
+
+	start := time.Now()
+	_, err = c.GetOrder(context.Background(), processedOrderID)
+	assert.NoError(err)
+	assert.GreaterOrEqual(time.Since(start), time.Millisecond*800, "request must wait for Retry-After")
+	assert.EqualValues(2, atomic.LoadInt32(calls))
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "seconds", header: "60", want: time.Minute},
+		{name: "http date", header: now.Add(time.Second * 30).Format(http.TimeFormat), want: time.Second * 30},
+		{name: "date in the past", header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{name: "missing", header: "", want: defaultRetryAfter},
+		{name: "garbage", header: "soon", want: defaultRetryAfter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.header, now))
+		})
+	}
+}
+
+func Test_parseRateLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "spec body", body: "No more than 120 requests per minute allowed", want: 120},
+		{name: "unknown body", body: "slow down", want: 0},
+		{name: "empty body", body: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRateLimit(tt.body))
+		})
+	}
+}
+
+func Test_limiter(t *testing.T) {
+	assert := assert.New(t)
+	l := newLimiter()
+	now := time.Now()
+
+	assert.Zero(l.reserve(now), "unlimited by default")
+
+	l.SetLimit(60)
+	for i := 0; i < 60; i++ {
+		assert.Zero(l.reserve(now), "burst of %d requests", i+1)
+	}
+	assert.InDelta(time.Second, l.reserve(now), float64(time.Millisecond*10))
+
+	l.Pause(now.Add(time.Minute))
+	assert.Equal(time.Minute, l.reserve(now))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	assert.ErrorIs(l.Wait(ctx), context.DeadlineExceeded)
+}
+
+func Test_limiter_SetLimitKeepsTokens(t *testing.T) {
+	assert := assert.New(t)
+	l := newLimiter()
+	now := time.Now()
+
+	l.SetLimit(60)
+	for i := 0; i < 60; i++ {
+		assert.Zero(l.reserve(now), "burst of %d requests", i+1)
+	}
+
+	// повторные ответы 429 с тем же лимитом не дают лишних запросов
+	l.SetLimit(60)
+	l.SetLimit(60)
+	assert.Positive(l.reserve(now))
+
+	// уменьшение лимита урезает накопленный запас
+	l = newLimiter()
+	l.SetLimit(60)
+	l.SetLimit(10)
+	for i := 0; i < 10; i++ {
+		assert.Zero(l.reserve(now), "burst of %d requests", i+1)
+	}
+	assert.Positive(l.reserve(now))
+}
+
+func Test_limiter_delay(t *testing.T) {
+	assert := assert.New(t)
+	l := newLimiter()
+	now := time.Now()
+
+	assert.Zero(l.delay(100, now), "unlimited by default")
+
+	l.SetLimit(60)
+	assert.Zero(l.delay(60, now.Add(time.Millisecond)))
+	assert.InDelta(time.Second*40, l.delay(100, now.Add(time.Millisecond)), float64(time.Millisecond*10))
+
+	// пауза добавляется к ожиданию токенов
+	l.Pause(now.Add(time.Minute))
+	assert.InDelta(time.Minute, l.delay(1, now), float64(time.Millisecond*10))
+	assert.InDelta(time.Minute+time.Second*40, l.delay(100, now), float64(time.Millisecond*10))
+}
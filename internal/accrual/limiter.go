@@ -0,0 +1,131 @@
+package accrual
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// limiter - общий для всех воркеров token bucket с возможностью полной паузы.
+// Пока лимит не объявлен системой начислений, запросы не ограничиваются.
+type limiter struct {
+	mu          sync.Mutex
+	perSecond   float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{}
+}
+
+// Wait блокирует вызывающего до окончания паузы и появления свободного токена
+func (l *limiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve(time.Now())
+		if d <= 0 {
+			return nil
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// reserve забирает токен и возвращает 0 либо время, через которое стоит повторить попытку
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.perSecond == 0 {
+		return 0
+	}
+
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.perSecond)
+		l.last = now
+	}
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.perSecond * float64(time.Second))
+}
+
+// delay оценивает, через сколько ограничение пропустит n запросов:
+// остаток паузы и время, за которое накопятся недостающие токены
+func (l *limiter) delay(n int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var d time.Duration
+	if now.Before(l.pausedUntil) {
+		d = l.pausedUntil.Sub(now)
+	}
+	if l.perSecond == 0 {
+		return d
+	}
+
+	tokens := l.tokens
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		tokens = math.Min(l.burst, tokens+elapsed.Seconds()*l.perSecond)
+	}
+	if missing := float64(n) - tokens; missing > 0 {
+		d += time.Duration(missing / l.perSecond * float64(time.Second))
+	}
+	return d
+}
+
+// Pause приостанавливает все запросы до указанного момента
+func (l *limiter) Pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// SetLimit подстраивает скорость под объявленное системой начислений число запросов в минуту.
+// Полный запас токенов выдаётся только при первом ограничении, дальше накопленные
+// токены сохраняются и лишь урезаются до нового запаса, иначе каждый ответ 429 пополнял бы его
+func (l *limiter) SetLimit(perMinute int) {
+	if perMinute < 1 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	burst := float64(perMinute)
+	if l.perSecond == 0 {
+		l.tokens = burst
+	} else {
+		if elapsed := now.Sub(l.last); elapsed > 0 {
+			l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.perSecond)
+		}
+		l.tokens = math.Min(burst, l.tokens)
+	}
+	l.perSecond = float64(perMinute) / 60
+	l.burst = burst
+	l.last = now
+}
+
+// Limit возвращает текущее ограничение в запросах в минуту, 0 - без ограничений
+func (l *limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(math.Round(l.perSecond * 60))
+}
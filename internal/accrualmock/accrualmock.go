@@ -0,0 +1,152 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nickzhog/gophermart/internal/accrual"
+	"github.com/nickzhog/gophermart/pkg/money"
+)
+
+// Rules описывает поведение подменной системы начислений
+type Rules struct {
+	Rewards         map[string]money.Amount // вознаграждение по префиксу номера заказа
+	DefaultReward   money.Amount            // вознаграждение, если ни один префикс не подошёл
+	InvalidRate     float64                 // доля заказов, получающих статус INVALID
+	ProcessingDelay time.Duration           // время от первого запроса до окончательного статуса
+	RateLimit       int                     // запросов в минуту, 0 - без ограничений
+}
+
+// ParseRewards разбирает правила вида "2377=100,5880=500.5"
+func ParseRewards(s string) (map[string]money.Amount, error) {
+	rewards := make(map[string]money.Amount)
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		prefix, reward, ok := strings.Cut(rule, "=")
+		if !ok || prefix == "" {
+			return nil, fmt.Errorf("bad reward rule %q, expected prefix=reward", rule)
+		}
+		amount, err := money.Parse(reward)
+		if err != nil {
+			return nil, fmt.Errorf("bad reward rule %q: %w", rule, err)
+		}
+		rewards[prefix] = amount
+	}
+	return rewards, nil
+}
+
+type orderState struct {
+	firstSeen time.Time
+	invalid   bool
+	reward    money.Amount
+}
+
+type Server struct {
+	rules Rules
+	now   func() time.Time
+
+	mu          sync.Mutex
+	rnd         *rand.Rand
+	orders      map[string]orderState
+	windowStart time.Time
+	requests    int
+}
+
+func NewServer(rules Rules) *Server {
+	return &Server{
+		rules:  rules,
+		now:    time.Now,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		orders: make(map[string]orderState),
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/api/orders/{number}", s.orderHandler)
+	return r
+}
+
+// получение информации о расчёте начислений баллов лояльности
+func (s *Server) orderHandler(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	s.mu.Lock()
+	now := s.now()
+	if retryAfter, limited := s.throttle(now); limited {
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(w, "No more than %d requests per minute allowed", s.rules.RateLimit)
+		return
+	}
+	state, ok := s.orders[number]
+	if !ok {
+		state = orderState{
+			firstSeen: now,
+			invalid:   s.rnd.Float64() < s.rules.InvalidRate,
+			reward:    s.reward(number),
+		}
+		s.orders[number] = state
+	}
+	s.mu.Unlock()
+
+	ans := accrual.Answer{Order: number}
+	elapsed := now.Sub(state.firstSeen)
+	switch {
+	case elapsed < s.rules.ProcessingDelay/2:
+		ans.Status = accrual.StatusRegistered
+	case elapsed < s.rules.ProcessingDelay:
+		ans.Status = accrual.StatusProcessing
+	case state.invalid:
+		ans.Status = accrual.StatusInvalid
+	default:
+		ans.Status = accrual.StatusProcessed
+		ans.Accrual = state.reward
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ans)
+}
+
+// throttle считает запросы в текущем минутном окне и сообщает, сколько ждать при превышении
+func (s *Server) throttle(now time.Time) (time.Duration, bool) {
+	if s.rules.RateLimit < 1 {
+		return 0, false
+	}
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart = now
+		s.requests = 0
+	}
+	if s.requests >= s.rules.RateLimit {
+		retryAfter := s.windowStart.Add(time.Minute).Sub(now).Round(time.Second)
+		if retryAfter < time.Second {
+			retryAfter = time.Second
+		}
+		return retryAfter, true
+	}
+	s.requests++
+	return 0, false
+}
+
+// reward выбирает вознаграждение по самому длинному подходящему префиксу
+func (s *Server) reward(number string) money.Amount {
+	reward, matched := s.rules.DefaultReward, -1
+	for prefix, amount := range s.rules.Rewards {
+		if strings.HasPrefix(number, prefix) && len(prefix) > matched {
+			reward, matched = amount, len(prefix)
+		}
+	}
+	return reward
+}
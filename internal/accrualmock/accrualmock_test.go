@@ -0,0 +1,132 @@
+package accrualmock
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/accrual"
+	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/pkg/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRewards(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]money.Amount
+		wantErr bool
+	}{
+		{
+			name: "several rules",
+			s:    "2377=100, 5880=500.5",
+			want: map[string]money.Amount{"2377": 10000, "5880": 50050},
+		},
+		{
+			name: "empty",
+			s:    "",
+			want: map[string]money.Amount{},
+		},
+		{
+			name:    "missing reward",
+			s:       "2377",
+			wantErr: true,
+		},
+		{
+			name:    "bad reward",
+			s:       "2377=abc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, err := ParseRewards(tt.s)
+			assert.Equal(tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(tt.want, got)
+			}
+		})
+	}
+}
+
+// prepareMock запускает подменную систему начислений с управляемыми часами
+func prepareMock(t *testing.T, rules Rules) (accrual.Client, *time.Time) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	mock := NewServer(rules)
+	mock.now = func() time.Time { return now }
+
+	srv := httptest.NewServer(mock.Handler())
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{}
+	cfg.Settings.AccrualSystemAddress = srv.URL
+	cfg.Settings.AccrualRequestTimeout = time.Second
+	return accrual.NewClient(cfg), &now
+}
+
+func TestServer_orderLifecycle(t *testing.T) {
+	assert := assert.New(t)
+	client, now := prepareMock(t, Rules{
+		Rewards:         map[string]money.Amount{"5880": 50050},
+		DefaultReward:   1000,
+		ProcessingDelay: time.Second * 2,
+	})
+	ctx := context.Background()
+
+	ans, err := client.GetOrder(ctx, "5880182")
+	require.NoError(t, err)
+	assert.Equal(accrual.StatusRegistered, ans.Status)
+
+	*now = now.Add(time.Second)
+	ans, err = client.GetOrder(ctx, "5880182")
+	require.NoError(t, err)
+	assert.Equal(accrual.StatusProcessing, ans.Status)
+
+	*now = now.Add(time.Second)
+	ans, err = client.GetOrder(ctx, "5880182")
+	require.NoError(t, err)
+	assert.Equal(accrual.Answer{Order: "5880182", Status: accrual.StatusProcessed, Accrual: 50050}, ans)
+
+	*now = now.Add(time.Second * 2)
+	ans, err = client.GetOrder(ctx, "71476808630764")
+	require.NoError(t, err)
+	assert.Equal(accrual.StatusRegistered, ans.Status, "delay counts from the first request of each order")
+}
+
+func TestServer_defaultRewardAndInvalid(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	client, _ := prepareMock(t, Rules{DefaultReward: 1000})
+	ans, err := client.GetOrder(ctx, "71476808630764")
+	require.NoError(t, err)
+	assert.Equal(accrual.Answer{Order: "71476808630764", Status: accrual.StatusProcessed, Accrual: 1000}, ans)
+
+	client, _ = prepareMock(t, Rules{DefaultReward: 1000, InvalidRate: 1})
+	ans, err = client.GetOrder(ctx, "71476808630764")
+	require.NoError(t, err)
+	assert.Equal(accrual.Answer{Order: "71476808630764", Status: accrual.StatusInvalid}, ans)
+}
+
+func TestServer_rateLimit(t *testing.T) {
+	assert := assert.New(t)
+	client, now := prepareMock(t, Rules{RateLimit: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetOrder(ctx, "5880182")
+		require.NoError(t, err)
+	}
+
+	*now = now.Add(time.Second * 20)
+	_, err := client.GetOrder(ctx, "5880182")
+	var rateErr *accrual.RateLimitError
+	require.True(t, errors.As(err, &rateErr))
+	assert.Equal(time.Second*40, rateErr.RetryAfter)
+	assert.Equal(2, rateErr.Limit)
+}
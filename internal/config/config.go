@@ -1,18 +1,60 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"time"
 
 	"github.com/caarlos0/env"
 )
 
+const (
+	// SessionModeDB хранит сессии в БД, их можно отозвать
+	SessionModeDB = "db"
+	// SessionModeToken выдаёт подписанные токены без обращения к БД
+	SessionModeToken = "token"
+)
+
+var ErrBadConfig = errors.New("bad config")
+
 type Config struct {
 	Settings struct {
-		RunAddress           string        `env:"RUN_ADDRESS"`
-		DatabaseURI          string        `env:"DATABASE_URI"`
-		AccrualSystemAddress string        `env:"ACCRUAL_SYSTEM_ADDRESS"`
-		AccrualScanInterval  time.Duration `env:"ACCRUAL_SCAN_INTERVAL"`
+		RunAddress            string        `env:"RUN_ADDRESS"`
+		DatabaseURI           string        `env:"DATABASE_URI"`
+		AccrualSystemAddress  string        `env:"ACCRUAL_SYSTEM_ADDRESS"`
+		AccrualScanInterval   time.Duration `env:"ACCRUAL_SCAN_INTERVAL"`
+		AccrualWorkers        int           `env:"ACCRUAL_WORKERS"`
+		AccrualBatchSize      int           `env:"ACCRUAL_BATCH_SIZE"`
+		AccrualRequestTimeout time.Duration `env:"ACCRUAL_REQUEST_TIMEOUT"`
+		AccrualBackoffBase    time.Duration `env:"ACCRUAL_BACKOFF_BASE"`
+		AccrualBackoffMax     time.Duration `env:"ACCRUAL_BACKOFF_MAX"`
+		SessionMode           string        `env:"SESSION_MODE"`
+		SessionKeys           string        `env:"SESSION_KEYS"`
+		SessionTTL            time.Duration `env:"SESSION_TTL"`
+		SessionIdleTimeout    time.Duration `env:"SESSION_IDLE_TIMEOUT"`
+		SessionPurgeInterval  time.Duration `env:"SESSION_PURGE_INTERVAL"`
+		SessionRevocationTTL  time.Duration `env:"SESSION_REVOCATION_TTL"`
+		SessionCookieSecure   bool          `env:"SESSION_COOKIE_SECURE"`
+		PasswordMinLength     int           `env:"PASSWORD_MIN_LENGTH"`
+		PasswordMinClasses    int           `env:"PASSWORD_MIN_CLASSES"`
+		PasswordCheckCommon   bool          `env:"PASSWORD_CHECK_COMMON"`
+		LoginMaxFailures      int           `env:"LOGIN_MAX_FAILURES"`
+		LoginMaxIPFailures    int           `env:"LOGIN_MAX_IP_FAILURES"`
+		LoginFailureWindow    time.Duration `env:"LOGIN_FAILURE_WINDOW"`
+		LoginLockoutBase      time.Duration `env:"LOGIN_LOCKOUT_BASE"`
+		LoginLockoutMax       time.Duration `env:"LOGIN_LOCKOUT_MAX"`
+		OrdersBatchLimit      int           `env:"ORDERS_BATCH_LIMIT"`
+		OrderEventsNotify     bool          `env:"ORDER_EVENTS_NOTIFY"`
+		AdminToken            string        `env:"ADMIN_TOKEN"`
+		TrustedProxies        string        `env:"TRUSTED_PROXIES"`
+		WebhookScanInterval   time.Duration `env:"WEBHOOK_SCAN_INTERVAL"`
+		WebhookWorkers        int           `env:"WEBHOOK_WORKERS"`
+		WebhookBatchSize      int           `env:"WEBHOOK_BATCH_SIZE"`
+		WebhookRequestTimeout time.Duration `env:"WEBHOOK_REQUEST_TIMEOUT"`
+		WebhookMaxAttempts    int           `env:"WEBHOOK_MAX_ATTEMPTS"`
+		WebhookBackoffBase    time.Duration `env:"WEBHOOK_BACKOFF_BASE"`
+		WebhookBackoffMax     time.Duration `env:"WEBHOOK_BACKOFF_MAX"`
 	}
 }
 
@@ -22,6 +64,37 @@ func GetConfig() *Config {
 	flag.StringVar(&cfg.Settings.DatabaseURI, "d", "", "Database URI")
 	flag.StringVar(&cfg.Settings.AccrualSystemAddress, "r", "", "accural system address")
 	flag.DurationVar(&cfg.Settings.AccrualScanInterval, "s", time.Millisecond*150, "accural scan interval")
+	flag.IntVar(&cfg.Settings.AccrualWorkers, "accrual-workers", 4, "number of concurrent accrual system requests")
+	flag.IntVar(&cfg.Settings.AccrualBatchSize, "accrual-batch-size", 100, "max orders fetched per scan")
+	flag.DurationVar(&cfg.Settings.AccrualRequestTimeout, "accrual-request-timeout", time.Second*4, "timeout of a single accrual system request")
+	flag.DurationVar(&cfg.Settings.AccrualBackoffBase, "accrual-backoff-base", time.Second, "initial delay between checks of an unfinished order")
+	flag.DurationVar(&cfg.Settings.AccrualBackoffMax, "accrual-backoff-max", time.Minute*5, "max delay between checks of an unfinished order")
+	flag.StringVar(&cfg.Settings.SessionMode, "session-mode", SessionModeDB, "session storage: db or token")
+	flag.StringVar(&cfg.Settings.SessionKeys, "session-keys", "", "token signing keys kid:secret, comma separated, first one signs")
+	flag.DurationVar(&cfg.Settings.SessionTTL, "session-ttl", time.Hour*24, "max lifetime of a session")
+	flag.DurationVar(&cfg.Settings.SessionIdleTimeout, "session-idle-timeout", time.Hour*2, "db session expires after that long without activity")
+	flag.DurationVar(&cfg.Settings.SessionPurgeInterval, "session-purge-interval", time.Hour, "interval of expired sessions cleanup")
+	flag.DurationVar(&cfg.Settings.SessionRevocationTTL, "session-revocation-ttl", time.Second*5, "token mode: how long a token revocation check is cached, 0 queries db on every request")
+	flag.BoolVar(&cfg.Settings.SessionCookieSecure, "session-cookie-secure", false, "send session cookie over https only")
+	flag.IntVar(&cfg.Settings.PasswordMinLength, "password-min-length", 8, "min password length")
+	flag.IntVar(&cfg.Settings.PasswordMinClasses, "password-min-classes", 2, "min number of character classes in password: lowercase, uppercase, digits, other")
+	flag.BoolVar(&cfg.Settings.PasswordCheckCommon, "password-check-common", true, "reject common passwords")
+	flag.IntVar(&cfg.Settings.LoginMaxFailures, "login-max-failures", 5, "failed logins in a row before the login is locked")
+	flag.IntVar(&cfg.Settings.LoginMaxIPFailures, "login-max-ip-failures", 50, "failed logins in a row before the client address is locked")
+	flag.DurationVar(&cfg.Settings.LoginFailureWindow, "login-failure-window", time.Minute*15, "failed logins counter resets after that long without failures")
+	flag.DurationVar(&cfg.Settings.LoginLockoutBase, "login-lockout-base", time.Minute, "first lockout duration, doubles on each next failure")
+	flag.DurationVar(&cfg.Settings.LoginLockoutMax, "login-lockout-max", time.Hour, "max lockout duration")
+	flag.IntVar(&cfg.Settings.OrdersBatchLimit, "orders-batch-limit", 100, "max order numbers in a single batch upload")
+	flag.BoolVar(&cfg.Settings.OrderEventsNotify, "order-events-notify", false, "deliver order events between instances with postgres LISTEN/NOTIFY")
+	flag.StringVar(&cfg.Settings.AdminToken, "admin-token", "", "bearer token of admin api, admin api is disabled if empty")
+	flag.StringVar(&cfg.Settings.TrustedProxies, "trusted-proxies", "", "addresses or CIDRs of reverse proxies, comma separated, allowed to set X-Forwarded-For and X-Real-IP")
+	flag.DurationVar(&cfg.Settings.WebhookScanInterval, "webhook-scan-interval", time.Second, "webhook outbox scan interval")
+	flag.IntVar(&cfg.Settings.WebhookWorkers, "webhook-workers", 4, "number of concurrent webhook deliveries")
+	flag.IntVar(&cfg.Settings.WebhookBatchSize, "webhook-batch-size", 100, "max webhook deliveries fetched per scan")
+	flag.DurationVar(&cfg.Settings.WebhookRequestTimeout, "webhook-request-timeout", time.Second*5, "timeout of a single webhook request")
+	flag.IntVar(&cfg.Settings.WebhookMaxAttempts, "webhook-max-attempts", 10, "webhook delivery attempts before it is marked dead")
+	flag.DurationVar(&cfg.Settings.WebhookBackoffBase, "webhook-backoff-base", time.Second*10, "initial delay between webhook delivery attempts")
+	flag.DurationVar(&cfg.Settings.WebhookBackoffMax, "webhook-backoff-max", time.Hour, "max delay between webhook delivery attempts")
 
 	flag.Parse()
 
@@ -29,3 +102,40 @@ func GetConfig() *Config {
 
 	return cfg
 }
+
+// Validate проверяет настройки, без которых фоновые задачи не могут работать
+func (c *Config) Validate() error {
+	positive := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"accrual-scan-interval", c.Settings.AccrualScanInterval},
+		{"accrual-request-timeout", c.Settings.AccrualRequestTimeout},
+		{"session-purge-interval", c.Settings.SessionPurgeInterval},
+		{"login-failure-window", c.Settings.LoginFailureWindow},
+		{"webhook-scan-interval", c.Settings.WebhookScanInterval},
+		{"webhook-request-timeout", c.Settings.WebhookRequestTimeout},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			return fmt.Errorf("%w: %s must be positive, got %s", ErrBadConfig, p.name, p.value)
+		}
+	}
+
+	// пустая пачка молча выключает опрос или отправку, а отрицательная ломает выборку из БД
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"accrual-workers", c.Settings.AccrualWorkers},
+		{"accrual-batch-size", c.Settings.AccrualBatchSize},
+		{"webhook-workers", c.Settings.WebhookWorkers},
+		{"webhook-batch-size", c.Settings.WebhookBatchSize},
+	}
+	for _, n := range counts {
+		if n.value < 1 {
+			return fmt.Errorf("%w: %s must be positive, got %d", ErrBadConfig, n.name, n.value)
+		}
+	}
+	return nil
+}
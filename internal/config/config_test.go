@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config {
+		cfg := &Config{}
+		cfg.Settings.AccrualScanInterval = time.Millisecond * 150
+		cfg.Settings.AccrualRequestTimeout = time.Second * 4
+		cfg.Settings.AccrualWorkers = 4
+		cfg.Settings.AccrualBatchSize = 100
+		cfg.Settings.SessionPurgeInterval = time.Hour
+		cfg.Settings.LoginFailureWindow = time.Minute * 15
+		cfg.Settings.WebhookScanInterval = time.Second
+		cfg.Settings.WebhookRequestTimeout = time.Second * 5
+		cfg.Settings.WebhookWorkers = 4
+		cfg.Settings.WebhookBatchSize = 100
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(cfg *Config) {}},
+		{name: "zero accrual scan interval", modify: func(cfg *Config) { cfg.Settings.AccrualScanInterval = 0 }, wantErr: true},
+		{name: "negative accrual request timeout", modify: func(cfg *Config) { cfg.Settings.AccrualRequestTimeout = -time.Second }, wantErr: true},
+		{name: "zero accrual workers", modify: func(cfg *Config) { cfg.Settings.AccrualWorkers = 0 }, wantErr: true},
+		{name: "zero accrual batch size", modify: func(cfg *Config) { cfg.Settings.AccrualBatchSize = 0 }, wantErr: true},
+		{name: "negative accrual batch size", modify: func(cfg *Config) { cfg.Settings.AccrualBatchSize = -1 }, wantErr: true},
+		{name: "zero session purge interval", modify: func(cfg *Config) { cfg.Settings.SessionPurgeInterval = 0 }, wantErr: true},
+		{name: "negative login failure window", modify: func(cfg *Config) { cfg.Settings.LoginFailureWindow = -time.Second }, wantErr: true},
+		{name: "zero webhook scan interval", modify: func(cfg *Config) { cfg.Settings.WebhookScanInterval = 0 }, wantErr: true},
+		{name: "zero webhook request timeout", modify: func(cfg *Config) { cfg.Settings.WebhookRequestTimeout = 0 }, wantErr: true},
+		{name: "zero webhook workers", modify: func(cfg *Config) { cfg.Settings.WebhookWorkers = 0 }, wantErr: true},
+		{name: "negative webhook batch size", modify: func(cfg *Config) { cfg.Settings.WebhookBatchSize = -1 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrBadConfig)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
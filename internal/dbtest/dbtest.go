@@ -0,0 +1,68 @@
+// Package dbtest готовит БД PostgreSQL для тестов репозиториев.
+// Адрес БД берётся из DATABASE_URI, без него тесты пропускаются
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/nickzhog/gophermart/internal/migration"
+	"github.com/nickzhog/gophermart/internal/service/user"
+	userdb "github.com/nickzhog/gophermart/internal/service/user/db"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/postgres"
+)
+
+// timeout ограничивает время одного теста с БД
+const timeout = time.Second * 30
+
+// DSN возвращает адрес БД с применёнными миграциями или пропускает тест
+func DSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv("DATABASE_URI")
+	if dsn == "" {
+		t.Skip("DATABASE_URI is not set")
+	}
+	if err := migration.Migrate(dsn); err != nil {
+		t.Fatalf("cant migrate: %s", err)
+	}
+	return dsn
+}
+
+// Connect возвращает контекст с таймаутом теста и пул соединений,
+// который закрывается по завершении теста
+func Connect(t *testing.T) (context.Context, postgres.Client) {
+	t.Helper()
+
+	dsn := DSN(t)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	t.Cleanup(cancel)
+
+	client, err := postgres.NewConnection(ctx, 1, dsn)
+	if err != nil {
+		t.Fatalf("cant connect: %s", err)
+	}
+	if pool, ok := client.(*pgxpool.Pool); ok {
+		t.Cleanup(pool.Close)
+	}
+	return ctx, client
+}
+
+// NewUser создаёт пользователя с уникальным логином, начинающимся с prefix
+func NewUser(ctx context.Context, t *testing.T, client postgres.Client, prefix string) user.User {
+	t.Helper()
+
+	usr, err := user.NewUser(fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano()), "Db-Test-Password-1", user.PasswordPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = userdb.NewRepository(client, logging.GetLogger()).Create(ctx, &usr); err != nil {
+		t.Fatalf("cant create user: %s", err)
+	}
+	return usr
+}
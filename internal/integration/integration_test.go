@@ -0,0 +1,439 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/accrual"
+	"github.com/nickzhog/gophermart/internal/accrualmock"
+	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/dbtest"
+	"github.com/nickzhog/gophermart/internal/orderevents"
+	"github.com/nickzhog/gophermart/internal/orderprocesser"
+	"github.com/nickzhog/gophermart/internal/repositories"
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	"github.com/nickzhog/gophermart/internal/web"
+	"github.com/nickzhog/gophermart/internal/webhooksender"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	reward     money.Amount = 50000
+	adminToken              = "integration-admin-token"
+)
+
+// suite - запущенный gophermart с подменной системой начислений
+type suite struct {
+	t      *testing.T
+	server *httptest.Server
+}
+
+func newSuite(t *testing.T) *suite {
+	dsn := dbtest.DSN(t)
+
+	logger := logging.GetLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mock := httptest.NewServer(accrualmock.NewServer(accrualmock.Rules{
+		DefaultReward: reward,
+	}).Handler())
+	t.Cleanup(mock.Close)
+
+	cfg := &config.Config{}
+	cfg.Settings.DatabaseURI = dsn
+	cfg.Settings.AccrualSystemAddress = mock.URL
+	cfg.Settings.AccrualScanInterval = time.Millisecond * 50
+	cfg.Settings.AccrualWorkers = 2
+	cfg.Settings.AccrualBatchSize = 10
+	cfg.Settings.AccrualRequestTimeout = time.Second
+	cfg.Settings.AccrualBackoffBase = time.Millisecond * 50
+	cfg.Settings.AccrualBackoffMax = time.Second
+	cfg.Settings.SessionMode = config.SessionModeDB
+	cfg.Settings.SessionTTL = time.Hour
+	cfg.Settings.SessionIdleTimeout = time.Minute * 10
+	cfg.Settings.LoginMaxFailures = 5
+	cfg.Settings.LoginMaxIPFailures = 50
+	cfg.Settings.LoginFailureWindow = time.Minute
+	cfg.Settings.LoginLockoutBase = time.Minute
+	cfg.Settings.LoginLockoutMax = time.Hour
+	cfg.Settings.OrdersBatchLimit = 10
+	cfg.Settings.AdminToken = adminToken
+	cfg.Settings.WebhookScanInterval = time.Millisecond * 50
+	cfg.Settings.WebhookWorkers = 2
+	cfg.Settings.WebhookBatchSize = 10
+	cfg.Settings.WebhookRequestTimeout = time.Second
+	cfg.Settings.WebhookMaxAttempts = 3
+	cfg.Settings.WebhookBackoffBase = time.Millisecond * 50
+	cfg.Settings.WebhookBackoffMax = time.Second
+
+	reps := repositories.GetRepositories(ctx, logger, cfg)
+	broker := orderevents.NewBroker(logger)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		orderprocesser.NewProcesser(logger, cfg, reps.Order, reps.Ledger, accrual.NewClient(cfg), broker).StartScan(ctx)
+	}()
+	go func() {
+		defer func() { done <- struct{}{} }()
+		webhooksender.NewSender(logger, cfg, reps.Webhook).StartDelivery(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		<-done
+	})
+
+	srv := httptest.NewServer(web.PrepareServer(logger, cfg, reps, broker).Handler)
+	t.Cleanup(srv.Close)
+
+	return &suite{t: t, server: srv}
+}
+
+// newClient возвращает HTTP-клиент со своей сессией
+func (s *suite) newClient() *http.Client {
+	jar, err := cookiejar.New(nil)
+	require.NoError(s.t, err)
+	return &http.Client{Jar: jar}
+}
+
+func (s *suite) do(c *http.Client, method, path, contentType, body string) (int, []byte) {
+	req, err := http.NewRequest(method, s.server.URL+path, bytes.NewBufferString(body))
+	require.NoError(s.t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return s.send(c, req)
+}
+
+// admin выполняет запрос к API администратора
+func (s *suite) admin(method, path, body string) (int, []byte) {
+	req, err := http.NewRequest(method, s.server.URL+"/api/admin"+path, bytes.NewBufferString(body))
+	require.NoError(s.t, err)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	return s.send(http.DefaultClient, req)
+}
+
+func (s *suite) send(c *http.Client, req *http.Request) (int, []byte) {
+	res, err := c.Do(req)
+	require.NoError(s.t, err)
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	require.NoError(s.t, err)
+	return res.StatusCode, data
+}
+
+func (s *suite) auth(c *http.Client, path, login, password string) int {
+	code, _ := s.do(c, http.MethodPost, path, "application/json",
+		fmt.Sprintf(`{"login":%q,"password":%q}`, login, password))
+	return code
+}
+
+func (s *suite) balance(c *http.Client) (current, withdrawn money.Amount) {
+	code, data := s.do(c, http.MethodGet, "/api/user/balance", "", "")
+	require.Equal(s.t, http.StatusOK, code)
+
+	var b struct {
+		Current   money.Amount `json:"current"`
+		Withdrawn money.Amount `json:"withdrawn"`
+	}
+	require.NoError(s.t, json.Unmarshal(data, &b))
+	return b.Current, b.Withdrawn
+}
+
+// events открывает поток событий заказов клиента, он закрывается по завершении теста
+func (s *suite) events(c *http.Client) <-chan orderevents.Event {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.server.URL+"/api/user/orders/events", nil)
+	require.NoError(s.t, err)
+	res, err := c.Do(req)
+	require.NoError(s.t, err)
+	require.Equal(s.t, http.StatusOK, res.StatusCode)
+
+	events := make(chan orderevents.Event, 16)
+	go func() {
+		defer res.Body.Close()
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			if !strings.HasPrefix(sc.Text(), "data: ") {
+				continue
+			}
+			var e orderevents.Event
+			if json.Unmarshal([]byte(strings.TrimPrefix(sc.Text(), "data: ")), &e) != nil {
+				continue
+			}
+			select {
+			case events <- e:
+			default:
+			}
+		}
+	}()
+	return events
+}
+
+// webhookRequest запрос, полученный подписчиком вебхуков
+type webhookRequest struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+// webhooks подписывает на все события приёмник, который принимает запросы,
+// и возвращает полученные им запросы. Подписка отключается по завершении теста
+func (s *suite) webhooks() <-chan webhookRequest {
+	requests := make(chan webhookRequest, 64)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		select {
+		case requests <- webhookRequest{
+			event:     r.Header.Get(webhook.HeaderEvent),
+			signature: r.Header.Get(webhook.HeaderSignature),
+			body:      body,
+		}:
+		default:
+		}
+	}))
+	s.t.Cleanup(receiver.Close)
+
+	code, data := s.admin(http.MethodPost, "/webhooks/subscriptions", fmt.Sprintf(`{"url":%q}`, receiver.URL))
+	require.Equal(s.t, http.StatusCreated, code)
+	var sub webhook.Subscription
+	require.NoError(s.t, json.Unmarshal(data, &sub))
+	require.NotEmpty(s.t, sub.Secret)
+	s.t.Cleanup(func() {
+		s.admin(http.MethodDelete, "/webhooks/subscriptions/"+sub.ID, "")
+	})
+
+	verified := make(chan webhookRequest, 64)
+	go func() {
+		for r := range requests {
+			if webhook.Verify(sub.Secret, r.signature, r.body, time.Now(), time.Minute) == nil {
+				verified <- r
+			}
+		}
+	}()
+	return verified
+}
+
+// luhnNumber возвращает уникальный для прогона номер заказа, проходящий проверку Луна
+func luhnNumber(seed int) string {
+	number := strconv.Itoa(seed)
+	d, err := order.CalculateLuhn(number)
+	if err != nil {
+		panic(err)
+	}
+	return number + strconv.Itoa(d)
+}
+
+// breakLuhn меняет последнюю цифру номера, после чего проверка Луна не проходит
+func breakLuhn(number string) string {
+	last := number[len(number)-1] - '0'
+	return number[:len(number)-1] + strconv.Itoa(int(last+1)%10)
+}
+
+func TestGophermart(t *testing.T) {
+	s := newSuite(t)
+	assert := assert.New(t)
+
+	seed := int(time.Now().UnixNano() / 1000 % 1e12)
+	login := fmt.Sprintf("integration-%d", seed)
+	password := "Integration-Pa55word"
+	orderNumber := luhnNumber(seed)
+	withdrawalNumber := luhnNumber(seed + 1)
+
+	alice := s.newClient()
+	bob := s.newClient()
+
+	// регистрация и аутентификация
+	assert.Equal(http.StatusOK, s.auth(alice, "/api/user/register", login, password))
+	assert.Equal(http.StatusConflict, s.auth(bob, "/api/user/register", login, password))
+	assert.Equal(http.StatusConflict, s.auth(bob, "/api/user/register", " "+strings.ToUpper(login)+" ", password))
+	assert.Equal(http.StatusUnauthorized, s.auth(bob, "/api/user/login", login, "wrong-password"))
+	assert.Equal(http.StatusOK, s.auth(alice, "/api/user/login", login, password))
+	assert.Equal(http.StatusOK, s.auth(bob, "/api/user/register", login+"-bob", password))
+
+	code, _ := s.do(s.newClient(), http.MethodGet, "/api/user/orders", "", "")
+	assert.Equal(http.StatusUnauthorized, code)
+
+	// поток событий открывается до загрузки, чтобы получить изменения заказа
+	events := s.events(alice)
+
+	// вебхуки доступны только администратору
+	code, _ = s.do(alice, http.MethodGet, "/api/admin/webhooks/subscriptions", "", "")
+	assert.Equal(http.StatusUnauthorized, code)
+	hooks := s.webhooks()
+
+	// загрузка заказов
+	code, _ = s.do(alice, http.MethodGet, "/api/user/orders", "", "")
+	assert.Equal(http.StatusNoContent, code)
+
+	code, _ = s.do(alice, http.MethodPost, "/api/user/orders", "text/plain", orderNumber)
+	assert.Equal(http.StatusAccepted, code)
+	code, _ = s.do(alice, http.MethodPost, "/api/user/orders", "text/plain", orderNumber)
+	assert.Equal(http.StatusOK, code)
+	code, _ = s.do(bob, http.MethodPost, "/api/user/orders", "text/plain", orderNumber)
+	assert.Equal(http.StatusConflict, code)
+	code, _ = s.do(alice, http.MethodPost, "/api/user/orders", "text/plain", breakLuhn(orderNumber))
+	assert.Equal(http.StatusUnprocessableEntity, code)
+
+	// пакетная загрузка
+	batchNumber := luhnNumber(seed + 2)
+	code, data := s.do(bob, http.MethodPost, "/api/user/orders/batch", "application/json",
+		fmt.Sprintf(`[%q, %q, %q, %q]`, batchNumber, batchNumber, orderNumber, breakLuhn(batchNumber)))
+	require.Equal(t, http.StatusOK, code)
+	var results []order.BatchResult
+	require.NoError(t, json.Unmarshal(data, &results))
+	require.Len(t, results, 4)
+	assert.Equal(order.BatchAccepted, results[0].Result)
+	assert.Equal(order.BatchAlreadyUploaded, results[1].Result)
+	assert.Equal(order.BatchOwnedByAnotherUser, results[2].Result)
+	assert.Equal(order.BatchInvalid, results[3].Result)
+
+	// начисление через систему расчёта баллов
+	require.Eventually(t, func() bool {
+		code, data := s.do(alice, http.MethodGet, "/api/user/orders", "", "")
+		if code != http.StatusOK {
+			return false
+		}
+		var orders []order.Order
+		require.NoError(t, json.Unmarshal(data, &orders))
+		return len(orders) == 1 && orders[0].Status == order.StatusProcessed
+	}, time.Second*10, time.Millisecond*100)
+
+	require.Eventually(t, func() bool {
+		for {
+			select {
+			case e := <-events:
+				if e.Number == orderNumber && e.Status == order.StatusProcessed {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, time.Second*5, time.Millisecond*50)
+
+	code, data = s.do(alice, http.MethodGet, "/api/user/orders/"+orderNumber, "", "")
+	require.Equal(t, http.StatusOK, code)
+	var details order.Details
+	require.NoError(t, json.Unmarshal(data, &details))
+	require.NotEmpty(t, details.History)
+	assert.Equal(order.StatusNew, details.History[0].Status)
+	assert.Equal(order.StatusProcessed, details.History[len(details.History)-1].Status)
+	assert.Equal(reward, details.History[len(details.History)-1].Accrual)
+	code, _ = s.do(bob, http.MethodGet, "/api/user/orders/"+orderNumber, "", "")
+	assert.Equal(http.StatusNotFound, code)
+
+	current, withdrawn := s.balance(alice)
+	assert.Equal(reward, current)
+	assert.Zero(withdrawn)
+
+	// списание
+	code, _ = s.do(alice, http.MethodGet, "/api/user/withdrawals", "", "")
+	assert.Equal(http.StatusNoContent, code)
+
+	code, _ = s.do(alice, http.MethodPost, "/api/user/balance/withdraw", "application/json",
+		fmt.Sprintf(`{"order":%q,"sum":%s}`, withdrawalNumber, (reward+1).String()))
+	assert.Equal(http.StatusPaymentRequired, code)
+
+	code, _ = s.do(alice, http.MethodPost, "/api/user/balance/withdraw", "application/json",
+		fmt.Sprintf(`{"order":%q,"sum":100.5}`, withdrawalNumber))
+	assert.Equal(http.StatusOK, code)
+
+	// повторное списание по тому же номеру не проходит, даже если баллов хватает
+	code, _ = s.do(alice, http.MethodPost, "/api/user/balance/withdraw", "application/json",
+		fmt.Sprintf(`{"order":%q,"sum":1}`, withdrawalNumber))
+	assert.Equal(http.StatusConflict, code)
+
+	code, _ = s.do(bob, http.MethodPost, "/api/user/balance/withdraw", "application/json",
+		fmt.Sprintf(`{"order":%q,"sum":1}`, withdrawalNumber))
+	assert.Equal(http.StatusPaymentRequired, code)
+
+	current, withdrawn = s.balance(alice)
+	assert.Equal(reward-10050, current)
+	assert.Equal(money.Amount(10050), withdrawn)
+
+	code, data = s.do(alice, http.MethodGet, "/api/user/withdrawals", "", "")
+	require.Equal(t, http.StatusOK, code)
+	var withdrawals []struct {
+		Order string       `json:"order"`
+		Sum   money.Amount `json:"sum"`
+	}
+	require.NoError(t, json.Unmarshal(data, &withdrawals))
+	require.Len(t, withdrawals, 1)
+	assert.Equal(withdrawalNumber, withdrawals[0].Order)
+	assert.Equal(money.Amount(10050), withdrawals[0].Sum)
+
+	// вебхуки начисления и списания
+	var accruedHook, withdrawalHook bool
+	require.Eventually(t, func() bool {
+		for {
+			select {
+			case r := <-hooks:
+				var e struct {
+					Event string          `json:"event"`
+					Data  json.RawMessage `json:"data"`
+				}
+				require.NoError(t, json.Unmarshal(r.body, &e))
+				assert.Equal(r.event, e.Event)
+				switch {
+				case e.Event == webhook.EventOrderAccrued && bytes.Contains(e.Data, []byte(orderNumber)):
+					accruedHook = true
+				case e.Event == webhook.EventWithdrawalCreated && bytes.Contains(e.Data, []byte(withdrawalNumber)):
+					withdrawalHook = true
+				}
+			default:
+				return accruedHook && withdrawalHook
+			}
+		}
+	}, time.Second*5, time.Millisecond*50)
+
+	code, data = s.admin(http.MethodGet, "/webhooks/deliveries?status=delivered&limit=1", "")
+	require.Equal(t, http.StatusOK, code)
+	var deliveries []webhook.Delivery
+	require.NoError(t, json.Unmarshal(data, &deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(webhook.DeliveryDelivered, deliveries[0].Status)
+
+	// сессии и выход
+	code, data = s.do(alice, http.MethodGet, "/api/user/sessions", "", "")
+	require.Equal(t, http.StatusOK, code)
+	var sessions []struct {
+		Current bool `json:"current"`
+	}
+	require.NoError(t, json.Unmarshal(data, &sessions))
+	require.NotEmpty(t, sessions)
+	assert.True(sessions[0].Current)
+
+	// смена пароля
+	newPassword := password + "-2"
+	code, _ = s.do(bob, http.MethodPost, "/api/user/password", "application/json",
+		fmt.Sprintf(`{"current_password":%q,"new_password":%q}`, password, newPassword))
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(http.StatusUnauthorized, s.auth(s.newClient(), "/api/user/login", login+"-bob", password))
+	assert.Equal(http.StatusOK, s.auth(s.newClient(), "/api/user/login", login+"-bob", newPassword))
+
+	code, _ = s.do(alice, http.MethodPost, "/api/user/logout", "", "")
+	assert.Equal(http.StatusOK, code)
+	code, _ = s.do(alice, http.MethodGet, "/api/user/balance", "", "")
+	assert.Equal(http.StatusUnauthorized, code)
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS public.balances;
+DROP TABLE IF EXISTS public.postings;
+DROP TABLE IF EXISTS public.journal_entries;
+DROP TABLE IF EXISTS public.ledger_accounts;
+DROP FUNCTION IF EXISTS public.ledger_immutable;
@@ -0,0 +1,110 @@
+CREATE TABLE IF NOT EXISTS public.ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    user_id UUID,
+    create_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id),
+    CONSTRAINT ledger_accounts_kind_user_id UNIQUE (kind, user_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ledger_accounts_system_kind
+    ON public.ledger_accounts (kind) WHERE user_id IS NULL;
+
+INSERT INTO public.ledger_accounts (kind) VALUES ('ACCRUAL'), ('WITHDRAWAL');
+
+CREATE TABLE IF NOT EXISTS public.journal_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    reference TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    create_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id),
+    CONSTRAINT journal_entries_kind_reference UNIQUE (kind, reference)
+);
+
+CREATE TABLE IF NOT EXISTS public.postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id UUID NOT NULL,
+    account_id UUID NOT NULL,
+    amount NUMERIC(20, 2) NOT NULL,
+    CONSTRAINT entry_id FOREIGN KEY (entry_id) REFERENCES public.journal_entries (id),
+    CONSTRAINT account_id FOREIGN KEY (account_id) REFERENCES public.ledger_accounts (id)
+);
+
+CREATE INDEX IF NOT EXISTS postings_account_id ON public.postings (account_id);
+
+CREATE TABLE IF NOT EXISTS public.balances (
+    user_id UUID PRIMARY KEY,
+    current NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    withdrawn NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    update_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id)
+);
+
+-- journal entries and postings are append-only
+CREATE OR REPLACE FUNCTION public.ledger_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger records are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_entries_immutable
+    BEFORE UPDATE OR DELETE ON public.journal_entries
+    FOR EACH ROW EXECUTE FUNCTION public.ledger_immutable();
+
+CREATE TRIGGER postings_immutable
+    BEFORE UPDATE OR DELETE ON public.postings
+    FOR EACH ROW EXECUTE FUNCTION public.ledger_immutable();
+
+-- backfill the ledger from already processed orders and withdrawals
+INSERT INTO public.ledger_accounts (kind, user_id)
+SELECT 'USER', id FROM public.users;
+
+INSERT INTO public.journal_entries (kind, reference, user_id, create_at)
+SELECT 'ACCRUAL', id, user_id, upload_at
+FROM public.orders
+WHERE status = 'PROCESSED' AND accrual::NUMERIC > 0;
+
+INSERT INTO public.journal_entries (kind, reference, user_id, create_at)
+SELECT 'WITHDRAWAL', id, user_id, processed_at
+FROM public.withdrawals;
+
+INSERT INTO public.postings (entry_id, account_id, amount)
+SELECT e.id, a.id, o.accrual::NUMERIC
+FROM public.journal_entries e
+JOIN public.orders o ON o.id = e.reference
+JOIN public.ledger_accounts a ON a.kind = 'USER' AND a.user_id = e.user_id
+WHERE e.kind = 'ACCRUAL';
+
+INSERT INTO public.postings (entry_id, account_id, amount)
+SELECT e.id, a.id, -o.accrual::NUMERIC
+FROM public.journal_entries e
+JOIN public.orders o ON o.id = e.reference
+JOIN public.ledger_accounts a ON a.kind = 'ACCRUAL' AND a.user_id IS NULL
+WHERE e.kind = 'ACCRUAL';
+
+INSERT INTO public.postings (entry_id, account_id, amount)
+SELECT e.id, a.id, -w.sum::NUMERIC
+FROM public.journal_entries e
+JOIN public.withdrawals w ON w.id = e.reference
+JOIN public.ledger_accounts a ON a.kind = 'USER' AND a.user_id = e.user_id
+WHERE e.kind = 'WITHDRAWAL';
+
+INSERT INTO public.postings (entry_id, account_id, amount)
+SELECT e.id, a.id, w.sum::NUMERIC
+FROM public.journal_entries e
+JOIN public.withdrawals w ON w.id = e.reference
+JOIN public.ledger_accounts a ON a.kind = 'WITHDRAWAL' AND a.user_id IS NULL
+WHERE e.kind = 'WITHDRAWAL';
+
+INSERT INTO public.balances (user_id, current, withdrawn)
+SELECT
+    u.id,
+    COALESCE((SELECT SUM(p.amount)
+        FROM public.postings p
+        JOIN public.ledger_accounts a ON a.id = p.account_id
+        WHERE a.kind = 'USER' AND a.user_id = u.id), 0),
+    COALESCE((SELECT SUM(w.sum::NUMERIC)
+        FROM public.withdrawals w
+        WHERE w.user_id = u.id), 0)
+FROM public.users u;
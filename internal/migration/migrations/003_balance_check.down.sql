@@ -0,0 +1 @@
+ALTER TABLE public.balances DROP CONSTRAINT IF EXISTS balances_current_non_negative;
@@ -0,0 +1,2 @@
+ALTER TABLE public.balances
+    ADD CONSTRAINT balances_current_non_negative CHECK (current >= 0) NOT VALID;
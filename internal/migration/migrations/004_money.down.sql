@@ -0,0 +1,6 @@
+ALTER TABLE public.orders
+    ALTER COLUMN accrual DROP DEFAULT,
+    ALTER COLUMN accrual TYPE TEXT USING accrual::TEXT;
+
+ALTER TABLE public.withdrawals
+    ALTER COLUMN sum TYPE TEXT USING sum::TEXT;
@@ -0,0 +1,6 @@
+ALTER TABLE public.orders
+    ALTER COLUMN accrual TYPE NUMERIC(20, 2) USING accrual::NUMERIC,
+    ALTER COLUMN accrual SET DEFAULT 0;
+
+ALTER TABLE public.withdrawals
+    ALTER COLUMN sum TYPE NUMERIC(20, 2) USING sum::NUMERIC;
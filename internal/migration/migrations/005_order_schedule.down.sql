@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS public.orders_next_check_at;
+
+ALTER TABLE public.orders
+    DROP COLUMN IF EXISTS next_check_at,
+    DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,7 @@
+ALTER TABLE public.orders
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_check_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS orders_next_check_at
+    ON public.orders (next_check_at)
+    WHERE status NOT IN ('INVALID', 'PROCESSED');
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS public.orders_user_id_upload_at;
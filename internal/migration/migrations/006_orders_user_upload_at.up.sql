@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS orders_user_id_upload_at
+    ON public.orders (user_id, upload_at, id);
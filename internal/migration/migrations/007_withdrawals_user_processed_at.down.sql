@@ -0,0 +1 @@
+DROP INDEX IF EXISTS public.withdrawals_user_id_processed_at;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS withdrawals_user_id_processed_at
+    ON public.withdrawals (user_id, processed_at, id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.sessions_create_at;
+DROP INDEX IF EXISTS public.sessions_last_seen_at;
+
+ALTER TABLE public.sessions DROP COLUMN IF EXISTS last_seen_at;
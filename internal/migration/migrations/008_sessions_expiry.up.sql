@@ -0,0 +1,7 @@
+ALTER TABLE public.sessions
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE public.sessions SET last_seen_at = create_at;
+
+CREATE INDEX IF NOT EXISTS sessions_last_seen_at ON public.sessions (last_seen_at);
+CREATE INDEX IF NOT EXISTS sessions_create_at ON public.sessions (create_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS public.sessions_user_id;
+DROP INDEX IF EXISTS public.sessions_public_id;
+
+ALTER TABLE public.sessions
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS public_id;
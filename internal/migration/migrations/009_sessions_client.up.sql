@@ -0,0 +1,7 @@
+ALTER TABLE public.sessions
+    ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_public_id ON public.sessions (public_id);
+CREATE INDEX IF NOT EXISTS sessions_user_id ON public.sessions (user_id) WHERE is_active;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS public.login_failures (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_last_failure_at ON public.login_failures (last_failure_at);
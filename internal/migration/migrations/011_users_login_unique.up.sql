@@ -0,0 +1,25 @@
+-- логины, совпадающие без учёта регистра и пробелов по краям, не переименовываются:
+-- миграция останавливается и перечисляет их, конфликт разрешается вручную
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(d.normalized || ' (' || d.users || ')', '; ')
+    INTO conflicts
+    FROM (
+        SELECT
+            lower(btrim(login)) AS normalized,
+            string_agg(id::TEXT || ' ' || quote_literal(login), ', ' ORDER BY create_at, id) AS users
+        FROM public.users
+        GROUP BY lower(btrim(login))
+        HAVING COUNT(*) > 1
+    ) d;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'duplicate logins must be resolved before creating users_login_normalized: %', conflicts;
+    END IF;
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_login_normalized
+    ON public.users (lower(btrim(login)));
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS public.order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    accrual NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT order_id FOREIGN KEY (order_id) REFERENCES public.orders (id)
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_changed_at
+    ON public.order_status_history (order_id, changed_at, id);
+
+-- история уже загруженных заказов: время промежуточных переходов неизвестно,
+-- поэтому сохраняется загрузка и текущий статус на момент миграции
+INSERT INTO public.order_status_history (order_id, status, accrual, changed_at)
+SELECT id, 'NEW', 0, upload_at
+FROM public.orders;
+
+INSERT INTO public.order_status_history (order_id, status, accrual)
+SELECT id, status, accrual
+FROM public.orders
+WHERE status <> 'NEW';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.webhook_outbox;
+DROP TABLE IF EXISTS public.webhook_subscriptions;
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS public.webhook_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    -- NULL - подписка на события всех пользователей
+    user_id UUID,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    active BOOL NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id)
+);
+
+-- исходящие события, по одной строке на подписку. Пишутся в транзакции,
+-- изменившей данные, и хранят состояние доставки
+CREATE TABLE IF NOT EXISTS public.webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id UUID NOT NULL,
+    event TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_status_code INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    CONSTRAINT subscription_id FOREIGN KEY (subscription_id) REFERENCES public.webhook_subscriptions (id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_outbox_next_attempt_at
+    ON public.webhook_outbox (next_attempt_at)
+    WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS webhook_outbox_subscription_id
+    ON public.webhook_outbox (subscription_id, id);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS public.revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at ON public.revoked_tokens (expires_at);
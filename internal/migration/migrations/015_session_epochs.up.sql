@@ -0,0 +1,7 @@
+-- эпоха токенов сессий пользователя: токены с меньшей эпохой, кроме kept_jti, отозваны
+CREATE TABLE IF NOT EXISTS public.session_epochs (
+    user_id UUID PRIMARY KEY,
+    epoch BIGINT NOT NULL DEFAULT 0,
+    kept_jti TEXT,
+    CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id)
+);
@@ -0,0 +1,91 @@
+package orderevents
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/postgres"
+)
+
+// Channel канал Postgres LISTEN/NOTIFY для событий заказов
+const Channel = "order_events"
+
+// listenRetryDelay - пауза перед переподключением слушателя
+const listenRetryDelay = time.Second * 5
+
+// notification событие вместе с пользователем, которое передаётся между экземплярами
+type notification struct {
+	Event
+	UserID string `json:"user_id"`
+}
+
+type notifier struct {
+	client postgres.Client
+}
+
+// NewNotifier публикует события через NOTIFY, их получают все экземпляры,
+// запустившие Listen, в том числе текущий
+func NewNotifier(client postgres.Client) Publisher {
+	return &notifier{client: client}
+}
+
+func (n *notifier) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(notification{Event: e, UserID: e.UserID})
+	if err != nil {
+		return err
+	}
+	_, err = n.client.Exec(ctx, `SELECT pg_notify($1, $2)`, Channel, string(payload))
+	return err
+}
+
+// Listen передаёт события из канала Channel в broker, пока не отменён ctx.
+// Для LISTEN из пула берётся отдельное соединение, при обрыве берётся новое
+func Listen(ctx context.Context, logger *logging.Logger, pool postgres.Client, broker *Broker) {
+	for {
+		err := listen(ctx, logger, pool, broker)
+		if ctx.Err() != nil {
+			logger.Trace("order events listener exited properly")
+			return
+		}
+		logger.Errorf("order events listener: %s", err.Error())
+
+		select {
+		case <-time.After(listenRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func listen(ctx context.Context, logger *logging.Logger, pool postgres.Client, broker *Broker) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// подписанное соединение нельзя вернуть в пул: в нём копились бы уведомления,
+	// поэтому оно закрывается, и пул вместо него откроет новое
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	if _, err = conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var msg notification
+		if err = json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			logger.Errorf("bad order event %q: %s", n.Payload, err.Error())
+			continue
+		}
+		msg.Event.UserID = msg.UserID
+		broker.Publish(ctx, msg.Event)
+	}
+}
@@ -0,0 +1,126 @@
+// Package orderevents доставляет события об изменении заказов подписчикам,
+// например потокам Server-Sent Events пользователей
+package orderevents
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/money"
+)
+
+// subscriberBuffer - сколько событий ждёт медленного подписчика, дальше события отбрасываются
+const subscriberBuffer = 16
+
+// Event изменение заказа пользователя
+type Event struct {
+	Number    string       `json:"number"`
+	UserID    string       `json:"-"`
+	Status    string       `json:"status"`
+	Accrual   money.Amount `json:"accrual,omitempty"`
+	ChangedAt time.Time    `json:"changed_at"`
+}
+
+// NewEvent возвращает событие о текущем состоянии заказа
+func NewEvent(o order.Order, changedAt time.Time) Event {
+	return Event{
+		Number:    o.ID,
+		UserID:    o.UserID,
+		Status:    o.Status,
+		Accrual:   o.Accrual,
+		ChangedAt: changedAt,
+	}
+}
+
+// Publisher публикует события об изменении заказов
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Subscriber выдаёт события заказов пользователя. Канал закрывается после отписки
+// или остановки источника событий
+type Subscriber interface {
+	Subscribe(usrID string) (<-chan Event, func())
+}
+
+// Broker раздаёт события подписчикам внутри процесса
+type Broker struct {
+	logger *logging.Logger
+
+	mu     sync.RWMutex
+	subs   map[string]map[chan Event]struct{}
+	closed bool
+}
+
+func NewBroker(logger *logging.Logger) *Broker {
+	return &Broker{
+		logger: logger,
+		subs:   make(map[string]map[chan Event]struct{}),
+	}
+}
+
+func (b *Broker) Subscribe(usrID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[usrID] == nil {
+		b.subs[usrID] = make(map[chan Event]struct{})
+	}
+	b.subs[usrID][ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() { b.unsubscribe(usrID, ch) })
+	}
+}
+
+func (b *Broker) unsubscribe(usrID string, ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[usrID][ch]; !ok {
+		return
+	}
+	delete(b.subs[usrID], ch)
+	if len(b.subs[usrID]) == 0 {
+		delete(b.subs, usrID)
+	}
+	close(ch)
+}
+
+// Publish не ждёт подписчиков: событие для переполненного канала отбрасывается
+func (b *Broker) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs[e.UserID] {
+		select {
+		case ch <- e:
+		default:
+			b.logger.Warnf("order event %s dropped for slow subscriber of user %s", e.Number, e.UserID)
+		}
+	}
+	return nil
+}
+
+// Close закрывает каналы всех подписчиков, например при остановке сервера
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for usrID, chs := range b.subs {
+		for ch := range chs {
+			close(ch)
+		}
+		delete(b.subs, usrID)
+	}
+}
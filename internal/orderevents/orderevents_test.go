@@ -0,0 +1,65 @@
+package orderevents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	b := NewBroker(logging.GetLogger())
+
+	alice, unsubscribeAlice := b.Subscribe("alice")
+	alice2, unsubscribeAlice2 := b.Subscribe("alice")
+	bob, unsubscribeBob := b.Subscribe("bob")
+	defer unsubscribeBob()
+
+	e := Event{Number: "5880182", UserID: "alice", Status: order.StatusProcessed}
+	assert.NoError(b.Publish(ctx, e))
+	assert.Equal(e, <-alice)
+	assert.Equal(e, <-alice2)
+	assert.Empty(bob)
+
+	// после отписки канал закрыт, повторная отписка безопасна
+	unsubscribeAlice()
+	unsubscribeAlice()
+	_, ok := <-alice
+	assert.False(ok)
+	assert.NoError(b.Publish(ctx, e))
+	assert.Equal(e, <-alice2)
+	unsubscribeAlice2()
+}
+
+func TestBroker_slowSubscriber(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBroker(logging.GetLogger())
+
+	ch, unsubscribe := b.Subscribe("alice")
+	defer unsubscribe()
+	for i := 0; i < subscriberBuffer*2; i++ {
+		assert.NoError(b.Publish(context.Background(), Event{UserID: "alice"}))
+	}
+	assert.Len(ch, subscriberBuffer)
+}
+
+func TestBroker_Close(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBroker(logging.GetLogger())
+
+	ch, unsubscribe := b.Subscribe("alice")
+	b.Close()
+	_, ok := <-ch
+	assert.False(ok)
+	unsubscribe()
+	b.Close()
+
+	ch, unsubscribe = b.Subscribe("alice")
+	defer unsubscribe()
+	_, ok = <-ch
+	assert.False(ok)
+}
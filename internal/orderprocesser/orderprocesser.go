@@ -2,14 +2,14 @@ package orderprocesser
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
-	"net/http"
+	"sync"
 	"time"
 
+	"github.com/nickzhog/gophermart/internal/accrual"
 	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/orderevents"
+	"github.com/nickzhog/gophermart/internal/service/ledger"
 	"github.com/nickzhog/gophermart/internal/service/order"
 	"github.com/nickzhog/gophermart/pkg/logging"
 )
@@ -19,98 +19,176 @@ type OrderProcesser interface {
 }
 
 type orderProcesser struct {
-	Logger   *logging.Logger
-	Cfg      *config.Config
-	OrderRep order.Repository
+	Logger    *logging.Logger
+	Cfg       *config.Config
+	OrderRep  order.Repository
+	LedgerRep ledger.Repository
+	Accrual   accrual.Client
+	Events    orderevents.Publisher
 }
 
-func NewProcesser(logger *logging.Logger, cfg *config.Config, orderRep order.Repository) OrderProcesser {
+func NewProcesser(logger *logging.Logger, cfg *config.Config,
+	orderRep order.Repository, ledgerRep ledger.Repository, accrualClient accrual.Client,
+	events orderevents.Publisher) OrderProcesser {
 	return &orderProcesser{
-		Logger:   logger,
-		Cfg:      cfg,
-		OrderRep: orderRep,
+		Logger:    logger,
+		Cfg:       cfg,
+		OrderRep:  orderRep,
+		LedgerRep: ledgerRep,
+		Accrual:   accrualClient,
+		Events:    events,
 	}
 }
 
+func (p *orderProcesser) workers() int {
+	if p.Cfg.Settings.AccrualWorkers < 1 {
+		return 1
+	}
+	return p.Cfg.Settings.AccrualWorkers
+}
+
+// lease - время, на которое заказы захватываются экземпляром сервиса для проверки.
+// Последний заказ пачки ждёт, пока воркеры проверят предыдущие, поэтому захват
+// покрывает все раунды проверки пачки с таймаутом запроса, ещё один раунд про запас
+// и ожидание в общем лимите запросов, включая паузу после ответа 429
+func (p *orderProcesser) lease() time.Duration {
+	batch, workers := p.Cfg.Settings.AccrualBatchSize, p.workers()
+	rounds := (batch + workers - 1) / workers
+	return time.Duration(rounds+1)*p.Cfg.Settings.AccrualRequestTimeout + p.Accrual.Delay(batch)
+}
+
 func (p *orderProcesser) StartScan(ctx context.Context) error {
+	workers := p.workers()
+
+	jobs := make(chan order.Order)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range jobs {
+				p.process(ctx, o)
+			}
+		}()
+	}
+
 	ticker := time.NewTicker(p.Cfg.Settings.AccrualScanInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			p.scan(ctx)
+			p.scan(ctx, jobs)
 		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
 			p.Logger.Trace("orders processing exited properly")
 			return nil
 		}
 	}
 }
 
-func (p *orderProcesser) scan(ctx context.Context) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*4)
-	defer cancel()
-	orders, err := p.OrderRep.FindForScanner(ctx)
+// scan раздаёт воркерам заказы, время проверки которых наступило
+func (p *orderProcesser) scan(ctx context.Context, jobs chan<- order.Order) {
+	lease := p.lease()
+	deadline := time.Now().Add(lease)
+	orders, err := p.OrderRep.FindForScanner(ctx, p.Cfg.Settings.AccrualBatchSize, lease)
 	if err != nil {
 		p.Logger.Error(err)
 		return
 	}
-	for _, o := range orders {
-		err = updateAccrual(ctx, p.Cfg.Settings.AccrualSystemAddress, &o)
-		if err != nil {
-			p.Logger.Error(err)
-			continue
+	for i, o := range orders {
+		// пауза, объявленная системой начислений после захвата, может не уложиться в него.
+		// Тогда оставшиеся заказы не раздаются и будут выбраны снова, когда захват истечёт
+		if time.Now().Add(p.Accrual.Delay(1)).After(deadline) {
+			p.Logger.Tracef("accrual requests are paused, %d orders left until next scan", len(orders)-i)
+			return
 		}
-		err = p.OrderRep.Update(ctx, &o)
-		if err != nil {
-			p.Logger.Error(err)
+		select {
+		case jobs <- o:
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-type Answer struct {
-	Order   string  `json:"order,omitempty"`
-	Status  string  `json:"status,omitempty"`
-	Accrual float64 `json:"accrual,omitempty"`
-}
-
-func updateAccrual(ctx context.Context, url string, order *order.Order) error {
-	fullURL := fmt.Sprintf("%s/api/orders/%s", url, order.ID)
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
-	if err != nil {
-		return err
-	}
-	res, err := http.DefaultClient.Do(request)
-	if err != nil {
-		return err
+func (p *orderProcesser) process(ctx context.Context, o order.Order) {
+	prevStatus := o.Status
+	changed := false
+	ans, err := p.Accrual.GetOrder(ctx, o.ID)
+	if ctx.Err() != nil {
+		return
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode == http.StatusTooManyRequests {
-		return errors.New("too many requests")
+	var rateErr *accrual.RateLimitError
+	switch {
+	case errors.As(err, &rateErr):
+		p.Logger.Warn(err)
+		o.NextCheckIn = rateErr.RetryAfter
+	case errors.Is(err, accrual.ErrNotRegistered):
+		p.Logger.Tracef("order %s is not registered in accrual system yet", o.ID)
+		o.ScheduleNextCheck(p.Cfg.Settings.AccrualBackoffBase, p.Cfg.Settings.AccrualBackoffMax)
+	case err != nil:
+		p.Logger.Error(err)
+		o.ScheduleNextCheck(p.Cfg.Settings.AccrualBackoffBase, p.Cfg.Settings.AccrualBackoffMax)
+	default:
+		if changed, err = applyAnswer(&o, ans); err != nil {
+			p.Logger.Warnf("order %s: %s", o.ID, err.Error())
+		}
+		if changed {
+			o.ResetSchedule()
+		} else {
+			o.ScheduleNextCheck(p.Cfg.Settings.AccrualBackoffBase, p.Cfg.Settings.AccrualBackoffMax)
+		}
 	}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err
+	// проводка идемпотентна, поэтому пишется до обновления заказа:
+	// при ошибке обновления заказ будет повторно обработан без двойного начисления
+	if err = p.postAccrual(ctx, o); err != nil {
+		p.Logger.Error(err)
+		return
 	}
+	err = p.OrderRep.Update(ctx, &o, prevStatus)
+	switch {
+	case errors.Is(err, order.ErrStatusChanged):
+		// заказ уже обновил другой экземпляр, его результат не перезаписывается
+		p.Logger.Tracef("order %s: %s", o.ID, err.Error())
+	case err != nil:
+		p.Logger.Error(err)
+	case changed:
+		if err = p.Events.Publish(ctx, orderevents.NewEvent(o, time.Now())); err != nil {
+			p.Logger.Errorf("cant publish order event: %s", err.Error())
+		}
+	}
+}
 
-	var ans Answer
-	err = json.Unmarshal(body, &ans)
+// applyAnswer переносит расчёт системы начислений в заказ и сообщает, изменился ли он.
+// Недопустимый переход статуса, например из окончательного, не применяется
+func applyAnswer(o *order.Order, ans accrual.Answer) (bool, error) {
+	status, err := ans.OrderStatus()
 	if err != nil {
-		return fmt.Errorf("url:%s,body:%s, err:%s",
-			fullURL, string(body), err.Error())
+		return false, err
 	}
-
-	if order.AccrualFloat == ans.Accrual &&
-		order.Status == ans.Status {
-		return errors.New("nothing changed")
+	if o.Status == status && o.Accrual == ans.Accrual {
+		return false, nil
 	}
-	if order.ID != ans.Order {
-		return errors.New("order ID changed")
+	if err = o.SetStatus(status); err != nil {
+		return false, err
 	}
+	o.Accrual = ans.Accrual
+	return true, nil
+}
 
-	order.Accrual = fmt.Sprintf("%g", ans.Accrual)
-	order.AccrualFloat = ans.Accrual
-	order.Status = ans.Status
-
+func (p *orderProcesser) postAccrual(ctx context.Context, o order.Order) error {
+	if o.Status != order.StatusProcessed || o.Accrual <= 0 {
+		return nil
+	}
+	entry, err := ledger.NewAccrualEntry(o.UserID, o.ID, o.Accrual)
+	if err != nil {
+		return err
+	}
+	err = p.LedgerRep.Post(ctx, &entry)
+	if err != nil && !errors.Is(err, ledger.ErrDuplicateEntry) {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,222 @@
+package orderprocesser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nickzhog/gophermart/internal/accrual"
+	mock_accrual "github.com/nickzhog/gophermart/internal/accrual/mocks"
+	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/orderevents"
+	"github.com/nickzhog/gophermart/internal/service/ledger"
+	mock_ledger "github.com/nickzhog/gophermart/internal/service/ledger/mocks"
+	"github.com/nickzhog/gophermart/internal/service/order"
+	mock_order "github.com/nickzhog/gophermart/internal/service/order/mocks"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/money"
+	"github.com/stretchr/testify/assert"
+)
+
+const validUserID = "ValidID"
+
+func Test_orderProcesser_process(t *testing.T) {
+	tests := []struct {
+		name        string
+		order       order.Order
+		answer      accrual.Answer
+		answerErr   error
+		wantStatus  string
+		wantAccrual money.Amount
+		wantPosted  bool
+		wantBackoff bool
+	}{
+		{
+			name:        "processed with accrual",
+			order:       order.Order{ID: "5880182", Status: order.StatusNew},
+			answer:      accrual.Answer{Order: "5880182", Status: accrual.StatusProcessed, Accrual: 50000},
+			wantStatus:  order.StatusProcessed,
+			wantAccrual: 50000,
+			wantPosted:  true,
+		},
+		{
+			name:       "registered becomes processing",
+			order:      order.Order{ID: "5880182", Status: order.StatusNew},
+			answer:     accrual.Answer{Order: "5880182", Status: accrual.StatusRegistered},
+			wantStatus: order.StatusProcessing,
+		},
+		{
+			name:        "nothing changed",
+			order:       order.Order{ID: "5880182", Status: order.StatusProcessing, Attempts: 2},
+			answer:      accrual.Answer{Order: "5880182", Status: accrual.StatusProcessing},
+			wantStatus:  order.StatusProcessing,
+			wantBackoff: true,
+		},
+		{
+			name:        "final order does not move back",
+			order:       order.Order{ID: "5880182", Status: order.StatusInvalid},
+			answer:      accrual.Answer{Order: "5880182", Status: accrual.StatusProcessing},
+			wantStatus:  order.StatusInvalid,
+			wantBackoff: true,
+		},
+		{
+			name:        "not registered yet",
+			order:       order.Order{ID: "5880182", Status: order.StatusNew},
+			answerErr:   accrual.ErrNotRegistered,
+			wantStatus:  order.StatusNew,
+			wantBackoff: true,
+		},
+		{
+			name:        "accrual system error",
+			order:       order.Order{ID: "5880182", Status: order.StatusNew},
+			answerErr:   accrual.ErrServer,
+			wantStatus:  order.StatusNew,
+			wantBackoff: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tt.order.UserID = validUserID
+			attempts := tt.order.Attempts
+
+			client := mock_accrual.NewMockClient(ctrl)
+			client.EXPECT().GetOrder(gomock.Any(), tt.order.ID).Return(tt.answer, tt.answerErr)
+
+			ledgerRep := mock_ledger.NewMockRepository(ctrl)
+			if tt.wantPosted {
+				ledgerRep.EXPECT().Post(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, e *ledger.Entry) error {
+						assert.Equal(ledger.EntryAccrual, e.Kind)
+						assert.Equal(tt.order.ID, e.Reference)
+						return e.Validate()
+					})
+			}
+
+			var updated order.Order
+			orderRep := mock_order.NewMockRepository(ctrl)
+			orderRep.EXPECT().Update(gomock.Any(), gomock.Any(), tt.order.Status).
+				DoAndReturn(func(ctx context.Context, o *order.Order, prevStatus string) error {
+					updated = *o
+					return nil
+				})
+
+			cfg := &config.Config{}
+			cfg.Settings.AccrualBackoffBase = time.Second
+			cfg.Settings.AccrualBackoffMax = time.Minute
+
+			events := orderevents.NewBroker(logging.GetLogger())
+			published, unsubscribe := events.Subscribe(validUserID)
+			defer unsubscribe()
+
+			p := NewProcesser(logging.GetLogger(), cfg, orderRep, ledgerRep, client, events).(*orderProcesser)
+			p.process(context.Background(), tt.order)
+
+			// событие публикуется только при изменении заказа
+			select {
+			case e := <-published:
+				assert.False(tt.wantBackoff)
+				assert.Equal(tt.order.ID, e.Number)
+				assert.Equal(tt.wantStatus, e.Status)
+				assert.Equal(tt.wantAccrual, e.Accrual)
+			default:
+				assert.True(tt.wantBackoff)
+			}
+
+			assert.Equal(tt.wantStatus, updated.Status)
+			assert.Equal(tt.wantAccrual, updated.Accrual)
+			if tt.wantBackoff {
+				assert.Equal(attempts+1, updated.Attempts)
+				assert.Positive(updated.NextCheckIn)
+			} else {
+				assert.Zero(updated.Attempts)
+			}
+		})
+	}
+}
+
+func Test_orderProcesser_process_statusChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	o := order.Order{ID: "5880182", UserID: validUserID, Status: order.StatusNew}
+
+	client := mock_accrual.NewMockClient(ctrl)
+	client.EXPECT().GetOrder(gomock.Any(), o.ID).
+		Return(accrual.Answer{Order: o.ID, Status: accrual.StatusProcessing}, nil)
+
+	// другой экземпляр успел обновить заказ: повторной записи нет
+	orderRep := mock_order.NewMockRepository(ctrl)
+	orderRep.EXPECT().Update(gomock.Any(), gomock.Any(), order.StatusNew).Times(1).
+		Return(order.ErrStatusChanged)
+
+	cfg := &config.Config{}
+	cfg.Settings.AccrualBackoffBase = time.Second
+	cfg.Settings.AccrualBackoffMax = time.Minute
+
+	events := orderevents.NewBroker(logging.GetLogger())
+	published, unsubscribe := events.Subscribe(validUserID)
+	defer unsubscribe()
+
+	p := NewProcesser(logging.GetLogger(), cfg, orderRep, mock_ledger.NewMockRepository(ctrl), client, events).(*orderProcesser)
+	p.process(context.Background(), o)
+	assert.Empty(t, published)
+}
+
+func Test_orderProcesser_lease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{}
+	cfg.Settings.AccrualBatchSize = 100
+	cfg.Settings.AccrualWorkers = 4
+	cfg.Settings.AccrualRequestTimeout = time.Second * 4
+
+	client := mock_accrual.NewMockClient(ctrl)
+	client.EXPECT().Delay(100).Return(time.Duration(0))
+	client.EXPECT().Delay(100).Return(time.Second * 30)
+
+	p := NewProcesser(logging.GetLogger(), cfg, nil, nil, client, nil).(*orderProcesser)
+
+	// 25 раундов проверки пачки и один про запас
+	assert.Equal(t, time.Second*104, p.lease())
+	assert.Equal(t, time.Second*134, p.lease(), "lease includes limiter pause")
+}
+
+func Test_orderProcesser_scan_paused(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{}
+	cfg.Settings.AccrualBatchSize = 3
+	cfg.Settings.AccrualWorkers = 1
+	cfg.Settings.AccrualRequestTimeout = time.Second
+
+	orders := []order.Order{{ID: "5880182"}, {ID: "2377225624"}, {ID: "79927398713"}}
+	orderRep := mock_order.NewMockRepository(ctrl)
+	orderRep.EXPECT().FindForScanner(gomock.Any(), 3, time.Second*4).Return(orders, nil)
+
+	// после раздачи первого заказа система начислений объявила паузу дольше захвата
+	client := mock_accrual.NewMockClient(ctrl)
+	client.EXPECT().Delay(3).Return(time.Duration(0))
+	gomock.InOrder(
+		client.EXPECT().Delay(1).Return(time.Duration(0)),
+		client.EXPECT().Delay(1).Return(time.Minute),
+	)
+
+	jobs := make(chan order.Order, len(orders))
+	p := NewProcesser(logging.GetLogger(), cfg, orderRep, nil, client, nil).(*orderProcesser)
+	p.scan(context.Background(), jobs)
+
+	close(jobs)
+	var got []string
+	for o := range jobs {
+		got = append(got, o.ID)
+	}
+	assert.Equal([]string{"5880182"}, got)
+}
@@ -5,14 +5,22 @@ import (
 	"time"
 
 	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/service/ledger"
+	ledgerdb "github.com/nickzhog/gophermart/internal/service/ledger/db"
+	"github.com/nickzhog/gophermart/internal/service/loginattempt"
+	loginattemptdb "github.com/nickzhog/gophermart/internal/service/loginattempt/db"
 	"github.com/nickzhog/gophermart/internal/service/order"
 	orderdb "github.com/nickzhog/gophermart/internal/service/order/db"
 	"github.com/nickzhog/gophermart/internal/service/user"
 	userdb "github.com/nickzhog/gophermart/internal/service/user/db"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	webhookdb "github.com/nickzhog/gophermart/internal/service/webhook/db"
 	"github.com/nickzhog/gophermart/internal/service/withdrawal"
 	withdrawaldb "github.com/nickzhog/gophermart/internal/service/withdrawal/db"
 	"github.com/nickzhog/gophermart/internal/web/session"
 	sessiondb "github.com/nickzhog/gophermart/internal/web/session/db"
+	sessiontoken "github.com/nickzhog/gophermart/internal/web/session/token"
+	sessiontokendb "github.com/nickzhog/gophermart/internal/web/session/token/db"
 	"github.com/nickzhog/gophermart/pkg/logging"
 	"github.com/nickzhog/gophermart/pkg/postgres"
 )
@@ -23,13 +31,22 @@ const (
 )
 
 type Repositories struct {
-	User       user.Repository
-	Order      order.Repository
-	Withdrawal withdrawal.Repository
-	Session    session.Repository
+	User         user.Repository
+	Order        order.Repository
+	Withdrawal   withdrawal.Repository
+	Session      session.Repository
+	Ledger       ledger.Repository
+	LoginAttempt loginattempt.Repository
+	Webhook      webhook.Repository
 }
 
+// GetRepositories подключается к БД и создаёт репозитории поверх нового пула соединений
 func GetRepositories(ctx context.Context, logger *logging.Logger, cfg *config.Config) Repositories {
+	return New(logger, cfg, Connect(ctx, logger, cfg))
+}
+
+// Connect открывает пул соединений с БД, общий для репозиториев и остальных частей сервиса
+func Connect(ctx context.Context, logger *logging.Logger, cfg *config.Config) postgres.Client {
 	ctx, cancel := context.WithTimeout(ctx, dbConnectTimeOut)
 	defer cancel()
 
@@ -40,10 +57,37 @@ func GetRepositories(ctx context.Context, logger *logging.Logger, cfg *config.Co
 	if err = pool.Ping(ctx); err != nil {
 		logger.Fatal(err)
 	}
+	return pool
+}
+
+// New создаёт репозитории поверх пула соединений pool
+func New(logger *logging.Logger, cfg *config.Config, pool postgres.Client) Repositories {
 	return Repositories{
-		User:       userdb.NewRepository(pool, logger),
-		Order:      orderdb.NewRepository(pool, logger),
-		Withdrawal: withdrawaldb.NewRepository(pool, logger),
-		Session:    sessiondb.NewRepository(pool, logger),
+		User:         userdb.NewRepository(pool, logger),
+		Order:        orderdb.NewRepository(pool, logger),
+		Withdrawal:   withdrawaldb.NewRepository(pool, logger),
+		Session:      newSessionRepository(logger, cfg, pool),
+		Ledger:       ledgerdb.NewRepository(pool, logger),
+		LoginAttempt: loginattemptdb.NewRepository(pool, logger),
+		Webhook:      webhookdb.NewRepository(pool, logger),
 	}
 }
+
+func newSessionRepository(logger *logging.Logger, cfg *config.Config, client postgres.Client) session.Repository {
+	switch cfg.Settings.SessionMode {
+	case config.SessionModeDB:
+		return sessiondb.NewRepository(client, logger,
+			cfg.Settings.SessionTTL, cfg.Settings.SessionIdleTimeout)
+	case config.SessionModeToken:
+		keys, err := sessiontoken.ParseKeys(cfg.Settings.SessionKeys)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		revoked := sessiontoken.NewCachedRevocations(sessiontokendb.NewRepository(client, logger),
+			cfg.Settings.SessionRevocationTTL)
+		return sessiontoken.NewRepository(keys, cfg.Settings.SessionTTL, revoked, logger)
+	}
+
+	logger.Fatalf("unknown session mode: %q", cfg.Settings.SessionMode)
+	return nil
+}
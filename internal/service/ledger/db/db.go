@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/nickzhog/gophermart/internal/service/ledger"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/postgres"
+)
+
+type repository struct {
+	client postgres.Client
+	logger *logging.Logger
+}
+
+func (r *repository) Post(ctx context.Context, e *ledger.Entry) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = Post(ctx, tx, e); err != nil {
+		if errors.Is(err, ledger.ErrDuplicateEntry) {
+			return err
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s",
+				pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error("err:", newErr.Error())
+		}
+		r.logger.Error("err:", err.Error())
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Post записывает проводку и обновляет баланс пользователя в рамках переданной транзакции.
+// Повторная проводка с тем же типом и основанием возвращает ledger.ErrDuplicateEntry.
+func Post(ctx context.Context, tx pgx.Tx, e *ledger.Entry) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	q := `
+		INSERT INTO public.journal_entries 
+		    (kind, reference, user_id) 
+		VALUES 
+		    ($1, $2, $3) 
+		ON CONFLICT (kind, reference) DO NOTHING
+		RETURNING id, create_at
+	`
+	err := tx.QueryRow(ctx, q, e.Kind, e.Reference, e.UserID).
+		Scan(&e.ID, &e.CreateAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ledger.ErrDuplicateEntry
+		}
+		return err
+	}
+
+	q = `
+		INSERT INTO public.postings 
+		    (entry_id, account_id, amount) 
+		VALUES 
+		    ($1, $2, $3)
+	`
+	for _, p := range e.Postings {
+		accountID, err := findAccount(ctx, tx, p.AccountKind, e.UserID)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, q, e.ID, accountID, p.Amount); err != nil {
+			return err
+		}
+	}
+
+	delta := e.BalanceDelta()
+	q = `
+		INSERT INTO public.balances 
+		    (user_id, current, withdrawn) 
+		VALUES 
+		    ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+		 current = balances.current + EXCLUDED.current,
+		 withdrawn = balances.withdrawn + EXCLUDED.withdrawn,
+		 update_at = CURRENT_TIMESTAMP
+	`
+	_, err = tx.Exec(ctx, q, delta.UserID, delta.Current, delta.Withdrawn)
+
+	return err
+}
+
+// findAccount возвращает идентификатор счёта, счёт пользователя создаётся при первом обращении
+func findAccount(ctx context.Context, tx pgx.Tx, kind, usrID string) (string, error) {
+	var id string
+	if kind != ledger.AccountUser {
+		q := `
+		SELECT id 
+		FROM public.ledger_accounts 
+		WHERE kind = $1 AND user_id IS NULL
+		`
+		err := tx.QueryRow(ctx, q, kind).Scan(&id)
+		return id, err
+	}
+
+	q := `
+		INSERT INTO public.ledger_accounts 
+		    (kind, user_id) 
+		VALUES 
+		    ($1, $2) 
+		ON CONFLICT (kind, user_id) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, q, kind, usrID); err != nil {
+		return "", err
+	}
+
+	q = `
+		SELECT id 
+		FROM public.ledger_accounts 
+		WHERE kind = $1 AND user_id = $2
+	`
+	err := tx.QueryRow(ctx, q, kind, usrID).Scan(&id)
+	return id, err
+}
+
+func (r *repository) FindForUser(ctx context.Context, usrID string) ([]ledger.Entry, error) {
+	q := `
+		SELECT 
+			e.id, e.kind, e.reference, e.user_id, e.create_at,
+			a.kind, p.amount
+		FROM public.journal_entries e
+		JOIN public.postings p ON p.entry_id = e.id
+		JOIN public.ledger_accounts a ON a.id = p.account_id
+		WHERE e.user_id = $1
+		ORDER BY e.create_at, e.id, p.id
+	`
+
+	rows, err := r.client.Query(ctx, q, usrID)
+	if err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]ledger.Entry, 0)
+
+	for rows.Next() {
+		var (
+			e ledger.Entry
+			p ledger.Posting
+		)
+
+		err = rows.Scan(&e.ID, &e.Kind, &e.Reference, &e.UserID, &e.CreateAt,
+			&p.AccountKind, &p.Amount)
+		if err != nil {
+			r.logger.Error(err)
+			return nil, err
+		}
+
+		if last := len(entries) - 1; last >= 0 && entries[last].ID == e.ID {
+			entries[last].Postings = append(entries[last].Postings, p)
+			continue
+		}
+		e.Postings = []ledger.Posting{p}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *repository) Balance(ctx context.Context, usrID string) (ledger.Balance, error) {
+	q := `
+	SELECT
+		user_id, current, withdrawn
+	FROM 
+		public.balances 
+	WHERE 
+		user_id = $1
+	`
+
+	var b ledger.Balance
+	err := r.client.QueryRow(ctx, q, usrID).
+		Scan(&b.UserID, &b.Current, &b.Withdrawn)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ledger.Balance{UserID: usrID}, nil
+		}
+		return ledger.Balance{}, err
+	}
+
+	return b, nil
+}
+
+func NewRepository(client postgres.Client, logger *logging.Logger) ledger.Repository {
+
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
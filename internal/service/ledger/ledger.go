@@ -0,0 +1,110 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/money"
+)
+
+// типы проводок
+const (
+	EntryAccrual    = "ACCRUAL"    // начисление баллов за заказ
+	EntryWithdrawal = "WITHDRAWAL" // списание баллов в счёт оплаты заказа
+)
+
+// типы счетов
+const (
+	AccountUser       = "USER"       // счёт баллов пользователя
+	AccountAccrual    = "ACCRUAL"    // системный счёт-источник начислений
+	AccountWithdrawal = "WITHDRAWAL" // системный счёт списаний
+)
+
+var (
+	ErrDuplicateEntry  = errors.New("entry already posted")
+	ErrUnbalancedEntry = errors.New("entry is not balanced")
+)
+
+// Posting - движение по одному счёту, положительная сумма увеличивает остаток
+type Posting struct {
+	AccountKind string
+	Amount      money.Amount
+}
+
+// Entry - неизменяемая проводка, сумма движений по которой равна нулю
+type Entry struct {
+	ID        string
+	Kind      string
+	Reference string
+	UserID    string
+	Postings  []Posting
+	CreateAt  time.Time
+}
+
+type Balance struct {
+	UserID    string       `json:"-"`
+	Current   money.Amount `json:"current"`
+	Withdrawn money.Amount `json:"withdrawn"`
+}
+
+func NewAccrualEntry(usrID, orderID string, amount money.Amount) (Entry, error) {
+	return newEntry(EntryAccrual, usrID, orderID, []Posting{
+		{AccountKind: AccountAccrual, Amount: -amount},
+		{AccountKind: AccountUser, Amount: amount},
+	})
+}
+
+func NewWithdrawalEntry(usrID, orderID string, amount money.Amount) (Entry, error) {
+	return newEntry(EntryWithdrawal, usrID, orderID, []Posting{
+		{AccountKind: AccountUser, Amount: -amount},
+		{AccountKind: AccountWithdrawal, Amount: amount},
+	})
+}
+
+func newEntry(kind, usrID, reference string, postings []Posting) (Entry, error) {
+	if len(usrID) < 1 || len(reference) < 1 {
+		return Entry{}, fmt.Errorf("empty data: usrID(%s), reference(%s)", usrID, reference)
+	}
+	for _, p := range postings {
+		if p.Amount == 0 {
+			return Entry{}, errors.New("zero amount")
+		}
+	}
+	e := Entry{
+		Kind:      kind,
+		Reference: reference,
+		UserID:    usrID,
+		Postings:  postings,
+	}
+	return e, e.Validate()
+}
+
+// Validate проверяет, что сумма движений проводки равна нулю
+func (e Entry) Validate() error {
+	if len(e.Postings) < 2 {
+		return ErrUnbalancedEntry
+	}
+	var sum money.Amount
+	for _, p := range e.Postings {
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return ErrUnbalancedEntry
+	}
+	return nil
+}
+
+// BalanceDelta возвращает изменение материализованного баланса пользователя
+func (e Entry) BalanceDelta() Balance {
+	delta := Balance{UserID: e.UserID}
+	for _, p := range e.Postings {
+		switch p.AccountKind {
+		case AccountUser:
+			delta.Current += p.Amount
+		case AccountWithdrawal:
+			delta.Withdrawn += p.Amount
+		}
+	}
+	return delta
+}
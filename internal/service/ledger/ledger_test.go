@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/nickzhog/gophermart/pkg/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEntry(t *testing.T) {
+	tests := []struct {
+		name      string
+		newEntry  func(usrID, orderID string, amount money.Amount) (Entry, error)
+		usrID     string
+		orderID   string
+		amount    money.Amount
+		wantErr   bool
+		wantDelta Balance
+	}{
+		{
+			name:      "accrual",
+			newEntry:  NewAccrualEntry,
+			usrID:     "usrID",
+			orderID:   "5880182",
+			amount:    50050,
+			wantDelta: Balance{UserID: "usrID", Current: 50050},
+		},
+		{
+			name:      "withdrawal",
+			newEntry:  NewWithdrawalEntry,
+			usrID:     "usrID",
+			orderID:   "2377225624",
+			amount:    4200,
+			wantDelta: Balance{UserID: "usrID", Current: -4200, Withdrawn: 4200},
+		},
+		{
+			name:     "zero amount",
+			newEntry: NewAccrualEntry,
+			usrID:    "usrID",
+			orderID:  "5880182",
+			amount:   0,
+			wantErr:  true,
+		},
+		{
+			name:     "empty usrID",
+			newEntry: NewWithdrawalEntry,
+			orderID:  "2377225624",
+			amount:   4200,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			e, err := tt.newEntry(tt.usrID, tt.orderID, tt.amount)
+			assert.Equal(tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(tt.wantDelta, e.BalanceDelta())
+			}
+		})
+	}
+}
+
+func TestEntry_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []Posting
+		wantErr  bool
+	}{
+		{
+			name: "balanced",
+			postings: []Posting{
+				{AccountKind: AccountAccrual, Amount: -10},
+				{AccountKind: AccountUser, Amount: 10},
+			},
+		},
+		{
+			name: "unbalanced",
+			postings: []Posting{
+				{AccountKind: AccountAccrual, Amount: -10},
+				{AccountKind: AccountUser, Amount: 9},
+			},
+			wantErr: true,
+		},
+		{
+			name: "single posting",
+			postings: []Posting{
+				{AccountKind: AccountUser, Amount: 0},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Entry{Postings: tt.postings}
+			assert.Equal(t, tt.wantErr, e.Validate() != nil)
+		})
+	}
+}
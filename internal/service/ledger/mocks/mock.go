@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: repository.go
+
+// Package mock_ledger is a generated GoMock package.
+package mock_ledger
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	ledger "github.com/nickzhog/gophermart/internal/service/ledger"
+)
+
+// MockRepository is a mock of Repository interface.
+type MockRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRepositoryMockRecorder
+}
+
+// MockRepositoryMockRecorder is the mock recorder for MockRepository.
+type MockRepositoryMockRecorder struct {
+	mock *MockRepository
+}
+
+// NewMockRepository creates a new mock instance.
+func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
+	mock := &MockRepository{ctrl: ctrl}
+	mock.recorder = &MockRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Balance mocks base method.
+func (m *MockRepository) Balance(ctx context.Context, usrID string) (ledger.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Balance", ctx, usrID)
+	ret0, _ := ret[0].(ledger.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Balance indicates an expected call of Balance.
+func (mr *MockRepositoryMockRecorder) Balance(ctx, usrID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockRepository)(nil).Balance), ctx, usrID)
+}
+
+// FindForUser mocks base method.
+func (m *MockRepository) FindForUser(ctx context.Context, usrID string) ([]ledger.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindForUser", ctx, usrID)
+	ret0, _ := ret[0].([]ledger.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindForUser indicates an expected call of FindForUser.
+func (mr *MockRepositoryMockRecorder) FindForUser(ctx, usrID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForUser", reflect.TypeOf((*MockRepository)(nil).FindForUser), ctx, usrID)
+}
+
+// Post mocks base method.
+func (m *MockRepository) Post(ctx context.Context, e *ledger.Entry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Post", ctx, e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Post indicates an expected call of Post.
+func (mr *MockRepositoryMockRecorder) Post(ctx, e interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Post", reflect.TypeOf((*MockRepository)(nil).Post), ctx, e)
+}
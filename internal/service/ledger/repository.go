@@ -0,0 +1,9 @@
+package ledger
+
+import "context"
+
+type Repository interface {
+	Post(ctx context.Context, e *Entry) error
+	FindForUser(ctx context.Context, usrID string) ([]Entry, error)
+	Balance(ctx context.Context, usrID string) (Balance, error)
+}
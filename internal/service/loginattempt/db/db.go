@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/service/loginattempt"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/postgres"
+)
+
+type repository struct {
+	client postgres.Client
+	logger *logging.Logger
+}
+
+func (r *repository) Reserve(ctx context.Context, key string, maxFailures int, p loginattempt.Policy) (loginattempt.Attempt, error) {
+	a := loginattempt.Attempt{Key: key, MaxFailures: maxFailures}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return loginattempt.Attempt{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+		INSERT INTO public.login_failures 
+			(key) 
+		VALUES 
+			($1)
+		ON CONFLICT (key) DO NOTHING
+	`
+	if _, err = tx.Exec(ctx, q, key); err != nil {
+		r.logger.Error(err)
+		return loginattempt.Attempt{}, err
+	}
+
+	// блокировка строки выстраивает параллельные попытки по ключу в очередь
+	q = `
+		SELECT 
+			failures,
+			last_failure_at < CURRENT_TIMESTAMP - make_interval(secs => $2::DOUBLE PRECISION),
+			COALESCE(EXTRACT(EPOCH FROM locked_until - CURRENT_TIMESTAMP), 0)::DOUBLE PRECISION
+		FROM 
+			public.login_failures 
+		WHERE 
+			key = $1 
+		FOR UPDATE
+	`
+	var (
+		expired    bool
+		lockedSecs float64
+	)
+	if err = tx.QueryRow(ctx, q, key, p.Window.Seconds()).Scan(&a.Failures, &expired, &lockedSecs); err != nil {
+		r.logger.Error(err)
+		return loginattempt.Attempt{}, err
+	}
+	if lockedSecs > 0 {
+		a.LockedFor = time.Duration(lockedSecs * float64(time.Second))
+		return a, nil
+	}
+
+	if expired {
+		a.Failures = 0
+	}
+	a.Failures++
+
+	q = `
+		UPDATE 
+			public.login_failures 
+		SET 
+			failures = $2,
+			last_failure_at = CURRENT_TIMESTAMP,
+			locked_until = CASE 
+				WHEN $3::DOUBLE PRECISION > 0 THEN CURRENT_TIMESTAMP + make_interval(secs => $3::DOUBLE PRECISION) 
+				ELSE locked_until 
+			END
+		WHERE 
+			key = $1
+	`
+	lockout := p.Lockout(a.Failures, maxFailures)
+	if _, err = tx.Exec(ctx, q, key, a.Failures, lockout.Seconds()); err != nil {
+		r.logger.Error(err)
+		return loginattempt.Attempt{}, err
+	}
+
+	return a, tx.Commit(ctx)
+}
+
+func (r *repository) Release(ctx context.Context, a loginattempt.Attempt, p loginattempt.Policy) error {
+	q := `
+		UPDATE 
+			public.login_failures 
+		SET 
+			failures = GREATEST(failures - 1, 0),
+			locked_until = CASE WHEN $2 THEN NULL ELSE locked_until END
+		WHERE 
+			key = $1
+	`
+	_, err := r.client.Exec(ctx, q, a.Key, a.Locks(p))
+	if err != nil {
+		r.logger.Error(err)
+	}
+	return err
+}
+
+func (r *repository) Reset(ctx context.Context, key string) error {
+	q := `
+		DELETE FROM 
+			public.login_failures 
+		WHERE 
+			key = $1
+	`
+	_, err := r.client.Exec(ctx, q, key)
+	if err != nil {
+		r.logger.Error(err)
+	}
+	return err
+}
+
+func (r *repository) PurgeExpired(ctx context.Context, window time.Duration) (int64, error) {
+	q := `
+		DELETE FROM 
+			public.login_failures 
+		WHERE 
+			last_failure_at < CURRENT_TIMESTAMP - make_interval(secs => $1::DOUBLE PRECISION)
+			AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+	`
+	tag, err := r.client.Exec(ctx, q, window.Seconds())
+	if err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func NewRepository(client postgres.Client, logger *logging.Logger) loginattempt.Repository {
+
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/dbtest"
+	"github.com/nickzhog/gophermart/internal/service/loginattempt"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_ReserveConcurrent(t *testing.T) {
+	const (
+		maxFailures = 3
+		attempts    = 20
+	)
+	policy := loginattempt.Policy{Window: time.Minute, LockoutBase: time.Minute, LockoutMax: time.Hour}
+
+	ctx, pool := dbtest.Connect(t)
+	rep := NewRepository(pool, logging.GetLogger())
+	key := loginattempt.LoginKey(fmt.Sprintf("reserve-race-%d", time.Now().UnixNano()))
+	defer rep.Reset(context.Background(), key)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved []loginattempt.Attempt
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, err := rep.Reserve(ctx, key, maxFailures, policy)
+			assert.NoError(t, err)
+			if err == nil && a.LockedFor == 0 {
+				mu.Lock()
+				reserved = append(reserved, a)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// проверить пароль успевают только попытки до блокировки
+	require.Len(t, reserved, maxFailures)
+
+	// отмена попытки, поставившей блокировку, снимает её
+	for _, a := range reserved {
+		if a.Locks(policy) {
+			require.NoError(t, rep.Release(ctx, a, policy))
+		}
+	}
+	a, err := rep.Reserve(ctx, key, maxFailures, policy)
+	require.NoError(t, err)
+	assert.Zero(t, a.LockedFor)
+	assert.Equal(t, maxFailures, a.Failures)
+}
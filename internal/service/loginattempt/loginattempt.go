@@ -0,0 +1,59 @@
+package loginattempt
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/apperr"
+)
+
+// Policy ограничивает неудачные попытки входа. Счётчик неудач ведётся отдельно
+// для логина и для адреса клиента и сбрасывается, если за Window неудач не было.
+// После MaxFailures неудач ключ блокируется на LockoutBase, и каждая следующая
+// неудача удваивает блокировку, но не больше LockoutMax.
+type Policy struct {
+	MaxLoginFailures int
+	MaxIPFailures    int
+	Window           time.Duration
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+}
+
+// Lockout возвращает длительность блокировки после failures неудач подряд
+func (p Policy) Lockout(failures, maxFailures int) time.Duration {
+	if maxFailures < 1 || failures < maxFailures {
+		return 0
+	}
+
+	d := p.LockoutBase
+	for i := maxFailures; i < failures && d < p.LockoutMax; i++ {
+		d *= 2
+	}
+	if d > p.LockoutMax {
+		d = p.LockoutMax
+	}
+	return d
+}
+
+// Attempt попытка входа, заранее учтённая как неудача по одному ключу
+type Attempt struct {
+	Key         string
+	Failures    int           // неудач подряд с учётом этой попытки
+	MaxFailures int           // предел неудач для ключа
+	LockedFor   time.Duration // ключ заблокирован, попытка не учтена
+}
+
+// Locks сообщает, что попытка исчерпала предел неудач и сама заблокировала ключ
+func (a Attempt) Locks(p Policy) bool {
+	return p.Lockout(a.Failures, a.MaxFailures) > 0
+}
+
+var ErrLocked = apperr.New(apperr.ErrTooManyRequests, "too many failed attempts")
+
+func LoginKey(login string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
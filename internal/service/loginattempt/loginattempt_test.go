@@ -0,0 +1,34 @@
+package loginattempt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Lockout(t *testing.T) {
+	p := Policy{LockoutBase: time.Minute, LockoutMax: time.Minute * 5}
+	tests := []struct {
+		name        string
+		failures    int
+		maxFailures int
+		want        time.Duration
+	}{
+		{name: "below limit", failures: 4, maxFailures: 5, want: 0},
+		{name: "first lockout", failures: 5, maxFailures: 5, want: time.Minute},
+		{name: "doubles", failures: 7, maxFailures: 5, want: time.Minute * 4},
+		{name: "capped", failures: 100, maxFailures: 5, want: time.Minute * 5},
+		{name: "disabled", failures: 100, maxFailures: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.Lockout(tt.failures, tt.maxFailures))
+		})
+	}
+}
+
+func TestLoginKey(t *testing.T) {
+	assert.Equal(t, LoginKey("Alice"), LoginKey(" alice "))
+	assert.NotEqual(t, LoginKey("127.0.0.1"), IPKey("127.0.0.1"))
+}
@@ -0,0 +1,35 @@
+package loginattempt
+
+import (
+	"context"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/logging"
+)
+
+// StartPurge раз в window удаляет устаревшие счётчики неудач до отмены контекста
+func StartPurge(ctx context.Context, logger *logging.Logger, rep Repository, window time.Duration) {
+	if window <= 0 {
+		logger.Warnf("login failure window %s is not positive, purge is disabled", window)
+		return
+	}
+
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := rep.PurgeExpired(ctx, window)
+			if err != nil {
+				logger.Errorf("cant purge login failures: %s", err.Error())
+				continue
+			}
+			if n > 0 {
+				logger.Tracef("purged %d login failure counters", n)
+			}
+		}
+	}
+}
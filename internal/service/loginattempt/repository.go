@@ -0,0 +1,20 @@
+package loginattempt
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// Reserve атомарно учитывает попытку входа как неудачу ещё до проверки пароля,
+	// поэтому параллельные попытки не проходят в одно незаблокированное окно.
+	// Если ключ заблокирован, попытка не учитывается и в Attempt.LockedFor возвращается
+	// оставшееся время блокировки. Попытка, исчерпавшая предел, сразу блокирует ключ по p
+	Reserve(ctx context.Context, key string, maxFailures int, p Policy) (Attempt, error)
+	// Release отменяет учёт попытки, которая не оказалась неудачной,
+	// и снимает блокировку, если её поставила эта попытка
+	Release(ctx context.Context, a Attempt, p Policy) error
+	Reset(ctx context.Context, key string) error
+	// PurgeExpired удаляет разблокированные ключи без неудач за window
+	PurgeExpired(ctx context.Context, window time.Duration) (int64, error)
+}
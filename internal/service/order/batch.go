@@ -0,0 +1,89 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nickzhog/gophermart/pkg/apperr"
+)
+
+// результаты загрузки номера в пакете
+const (
+	BatchAccepted           = "accepted"              // новый заказ принят в обработку
+	BatchAlreadyUploaded    = "already_uploaded"      // заказ уже загружен этим пользователем
+	BatchOwnedByAnotherUser = "owned_by_another_user" // заказ загружен другим пользователем
+	BatchInvalid            = "invalid"               // неверный формат номера или проверка Луна
+)
+
+const (
+	// batchBytesPerOrder размер тела пакета на один номер с запасом на кавычки, разделители и пробелы
+	batchBytesPerOrder = 64
+	// maxBatchBytes размер тела пакета без ограничения числа номеров
+	maxBatchBytes = 1 << 20
+)
+
+var (
+	ErrAlreadyUploaded = apperr.New(apperr.ErrConflict, "order already uploaded by user")
+	ErrBadBatch        = apperr.New(apperr.ErrBadRequest, "bad orders batch")
+	ErrBatchTooLarge   = apperr.New(apperr.ErrTooLarge, "too many orders in batch")
+)
+
+// BatchResult результат загрузки одного номера из пакета
+type BatchResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MaxBatchBytes возвращает допустимый размер тела пакета из limit номеров, limit <= 0 не ограничивает число номеров
+func MaxBatchBytes(limit int) int64 {
+	if limit <= 0 {
+		return maxBatchBytes
+	}
+	return int64(limit) * batchBytesPerOrder
+}
+
+// ParseBatch разбирает пакет номеров: JSON-массив строк, если isJSON,
+// иначе номера по одному в строке. Пустые строки пропускаются
+func ParseBatch(data []byte, isJSON bool, limit int) ([]string, error) {
+	var numbers []string
+	if isJSON {
+		if err := json.Unmarshal(data, &numbers); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrBadBatch, err.Error())
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			numbers = append(numbers, line)
+		}
+	}
+
+	if len(numbers) < 1 {
+		return nil, fmt.Errorf("%w: no orders", ErrBadBatch)
+	}
+	if limit > 0 && len(numbers) > limit {
+		return nil, fmt.Errorf("%w: %d orders, limit is %d", ErrBatchTooLarge, len(numbers), limit)
+	}
+	return numbers, nil
+}
+
+// NewBatchResult возвращает результат загрузки номера по ошибке его создания или сохранения
+func NewBatchResult(number string, err error) BatchResult {
+	res := BatchResult{Number: strings.TrimSpace(number)}
+	switch {
+	case err == nil:
+		res.Result = BatchAccepted
+	case errors.Is(err, ErrAlreadyUploaded):
+		res.Result = BatchAlreadyUploaded
+	case errors.Is(err, ErrOwnedByAnotherUser):
+		res.Result = BatchOwnedByAnotherUser
+	default:
+		res.Result = BatchInvalid
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,101 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		isJSON  bool
+		limit   int
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "json",
+			data:   `["5880182", " 71476808630764"]`,
+			isJSON: true,
+			limit:  2,
+			want:   []string{"5880182", " 71476808630764"},
+		},
+		{
+			name:  "lines",
+			data:  "5880182\r\n\n  \n71476808630764",
+			limit: 2,
+			want:  []string{"5880182\r", "71476808630764"},
+		},
+		{
+			name: "no limit",
+			data: "1\n2\n3",
+			want: []string{"1", "2", "3"},
+		},
+		{
+			name:    "over limit",
+			data:    "1\n2\n3",
+			limit:   2,
+			wantErr: ErrBatchTooLarge,
+		},
+		{
+			name:    "numbers in json",
+			data:    `[5880182]`,
+			isJSON:  true,
+			wantErr: ErrBadBatch,
+		},
+		{
+			name:    "empty json",
+			data:    `[]`,
+			isJSON:  true,
+			wantErr: ErrBadBatch,
+		},
+		{
+			name:    "empty text",
+			data:    "\n \n",
+			wantErr: ErrBadBatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, err := ParseBatch([]byte(tt.data), tt.isJSON, tt.limit)
+			if tt.wantErr != nil {
+				assert.ErrorIs(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestNewBatchResult(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want BatchResult
+	}{
+		{name: "accepted", want: BatchResult{Number: "5880182", Result: BatchAccepted}},
+		{name: "already uploaded", err: ErrAlreadyUploaded, want: BatchResult{Number: "5880182", Result: BatchAlreadyUploaded}},
+		{name: "another user", err: ErrOwnedByAnotherUser, want: BatchResult{Number: "5880182", Result: BatchOwnedByAnotherUser}},
+		{
+			name: "invalid",
+			err:  fmt.Errorf("%w: luhn check fail", ErrInvalidNumber),
+			want: BatchResult{Number: "5880182", Result: BatchInvalid, Error: "invalid order number: luhn check fail"},
+		},
+		{
+			name: "other error",
+			err:  errors.New("unexpected"),
+			want: BatchResult{Number: "5880182", Result: BatchInvalid, Error: "unexpected"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewBatchResult(" 5880182\r", tt.err))
+		})
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
+	"sort"
+	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	webhookdb "github.com/nickzhog/gophermart/internal/service/webhook/db"
 	"github.com/nickzhog/gophermart/pkg/logging"
 	"github.com/nickzhog/gophermart/pkg/postgres"
 )
@@ -18,20 +21,16 @@ type repository struct {
 	logger *logging.Logger
 }
 
-func (r *repository) Create(ctx context.Context, o *order.Order) error {
-	q := `
-		INSERT INTO public.orders 
-		    (id, user_id, accrual) 
-		VALUES 
-		    ($1, $2, $3) 
-		RETURNING status, upload_at
-	`
-	err := r.client.QueryRow(ctx, q, o.ID, o.UserID, o.Accrual).
-		Scan(&o.Status, &o.UploadAt)
-	if err != nil {
+// queryRower общий для пула и транзакции метод
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+func (r *repository) Claim(ctx context.Context, o *order.Order) error {
+	err := claim(ctx, r.client, o)
+	if err != nil && !isClaimed(err) {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s",
 				pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error("err:", newErr.Error())
@@ -41,6 +40,97 @@ func (r *repository) Create(ctx context.Context, o *order.Order) error {
 	return err
 }
 
+func (r *repository) CreateBatch(ctx context.Context, orders []*order.Order) ([]error, error) {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// номера занимаются по возрастанию и по одному разу, чтобы пакеты с пересекающимися
+	// номерами брали блокировки в одном порядке и не блокировали друг друга взаимно
+	idx := make([]int, len(orders))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return orders[idx[a]].ID < orders[idx[b]].ID
+	})
+
+	results := make([]error, len(orders))
+	for k, i := range idx {
+		o := orders[i]
+		if k > 0 && orders[idx[k-1]].ID == o.ID {
+			// повтор номера в пакете получает результат первого вхождения,
+			// а принятый номер считается уже загруженным
+			results[i] = results[idx[k-1]]
+			if results[i] == nil {
+				results[i] = order.ErrAlreadyUploaded
+			}
+			continue
+		}
+		err = claim(ctx, tx, o)
+		switch {
+		case isClaimed(err):
+			results[i] = err
+		case err != nil:
+			r.logger.Error("err:", err.Error())
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// claim вставляет заказ, если номер ещё не загружен. При конфликте вставка
+// дожидается завершения конкурирующей транзакции, поэтому владелец уже виден
+func claim(ctx context.Context, q queryRower, o *order.Order) error {
+	insert := `
+		WITH claimed AS (
+			INSERT INTO public.orders 
+			    (id, user_id, accrual) 
+			VALUES 
+			    ($1, $2, $3) 
+			ON CONFLICT (id) DO NOTHING
+			RETURNING id, status, accrual, upload_at
+		), history AS (
+			INSERT INTO public.order_status_history 
+			    (order_id, status, accrual, changed_at)
+			SELECT id, status, accrual, upload_at 
+			FROM claimed
+		)
+		SELECT status, upload_at 
+		FROM claimed
+	`
+	err := q.QueryRow(ctx, insert, o.ID, o.UserID, o.Accrual).
+		Scan(&o.Status, &o.UploadAt)
+	if err != pgx.ErrNoRows {
+		return err
+	}
+
+	owner := `
+		SELECT user_id 
+		FROM public.orders 
+		WHERE id = $1
+	`
+	var usrID string
+	if err = q.QueryRow(ctx, owner, o.ID).Scan(&usrID); err != nil {
+		return err
+	}
+	if usrID == o.UserID {
+		return order.ErrAlreadyUploaded
+	}
+	return order.ErrOwnedByAnotherUser
+}
+
+// isClaimed сообщает, что номер уже загружен, а не произошла ошибка БД
+func isClaimed(err error) bool {
+	return errors.Is(err, order.ErrAlreadyUploaded) || errors.Is(err, order.ErrOwnedByAnotherUser)
+}
+
 func (r *repository) FindByID(ctx context.Context, id string) (order.Order, error) {
 	q := `
 	SELECT
@@ -63,29 +153,88 @@ func (r *repository) FindByID(ctx context.Context, id string) (order.Order, erro
 		return order.Order{}, err
 	}
 
-	if o.AccrualFloat, err = strconv.ParseFloat(o.Accrual, 64); err != nil {
-		return order.Order{}, err
+	return o, nil
+}
+
+func (r *repository) History(ctx context.Context, id string) ([]order.StatusChange, error) {
+	q := `
+		SELECT 
+			status, accrual, changed_at
+		FROM public.order_status_history 
+		WHERE order_id = $1
+		ORDER BY changed_at, id
+	`
+
+	rows, err := r.client.Query(ctx, q, id)
+	if err != nil {
+		r.logger.Error(err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	return o, nil
+	history := make([]order.StatusChange, 0)
+
+	for rows.Next() {
+		var c order.StatusChange
+
+		if err = rows.Scan(&c.Status, &c.Accrual, &c.ChangedAt); err != nil {
+			r.logger.Error(err)
+			return nil, err
+		}
+
+		history = append(history, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return history, nil
 }
 
-func (r *repository) FindForUser(ctx context.Context, usrID string) ([]order.Order, error) {
+func (r *repository) FindForUser(ctx context.Context, usrID string, filter order.Filter) ([]order.Order, error) {
 	q := `
 		SELECT 
 			id, user_id, status, 
 			accrual, upload_at
 		FROM public.orders 
-		WHERE user_id = $1;
+		WHERE 
+			user_id = $1
+			AND ($2::TEXT[] IS NULL OR status = ANY($2::TEXT[]))
+			AND ($3::TIMESTAMP IS NULL OR upload_at >= $3::TIMESTAMP)
+			AND ($4::TIMESTAMP IS NULL OR upload_at < $4::TIMESTAMP)
+			AND ($5::TIMESTAMP IS NULL OR (upload_at, id) > ($5::TIMESTAMP, $6::TEXT))
+		ORDER BY upload_at, id
+		LIMIT $7
 	`
 
-	rows, err := r.client.Query(ctx, q, usrID)
+	var statuses, from, to, afterUploadAt, afterID, limit interface{}
+	if len(filter.Statuses) > 0 {
+		statuses = filter.Statuses
+	}
+	if !filter.From.IsZero() {
+		from = filter.From.UTC()
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.UTC()
+	}
+	if filter.After != nil {
+		afterUploadAt, afterID = filter.After.At.UTC(), filter.After.ID
+	}
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+
+	rows, err := r.client.Query(ctx, q, usrID,
+		statuses, from, to, afterUploadAt, afterID, limit)
 	if err != nil {
 		r.logger.Error(err)
 		return nil, err
 	}
+	defer rows.Close()
 
-	orders := make([]order.Order, rows.CommandTag().RowsAffected())
+	orders := make([]order.Order, 0)
 
 	for rows.Next() {
 		var o order.Order
@@ -97,10 +246,6 @@ func (r *repository) FindForUser(ctx context.Context, usrID string) ([]order.Ord
 			r.logger.Error(err)
 			return nil, err
 		}
-		if o.AccrualFloat, err = strconv.ParseFloat(o.Accrual, 64); err != nil {
-			r.logger.Error(err)
-			return nil, err
-		}
 
 		orders = append(orders, o)
 	}
@@ -113,58 +258,103 @@ func (r *repository) FindForUser(ctx context.Context, usrID string) ([]order.Ord
 	return orders, nil
 }
 
-func (r *repository) Update(ctx context.Context, o *order.Order) error {
-
-	o.Accrual = fmt.Sprintf("%g", o.AccrualFloat)
+func (r *repository) Update(ctx context.Context, o *order.Order, prevStatus string) error {
+	if !order.CanTransition(prevStatus, o.Status) {
+		return fmt.Errorf("%w: order %s: %s -> %s", order.ErrIllegalTransition, o.ID, prevStatus, o.Status)
+	}
 
+	// условие на статус не даёт параллельной проверке откатить заказ назад
 	q := `
-		UPDATE public.orders 
-		SET
-		 status = $1,
-		 accrual = $2
-		WHERE id = $3
+		WITH updated AS (
+			UPDATE public.orders
+			SET
+			 status = $1,
+			 accrual = $2,
+			 attempts = $3,
+			 next_check_at = CURRENT_TIMESTAMP + make_interval(secs => $4::DOUBLE PRECISION)
+			WHERE id = $5 AND status = $6
+			RETURNING id, status, accrual
+		), history AS (
+			INSERT INTO public.order_status_history 
+			    (order_id, status, accrual)
+			SELECT id, status, accrual 
+			FROM updated 
+			WHERE status <> $6
+		)
+		SELECT count(*), CURRENT_TIMESTAMP 
+		FROM updated
 	`
 
-	_, err := r.client.Exec(ctx, q,
-		o.Status, o.Accrual, o.ID)
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		n           int
+		processedAt time.Time
+	)
+	err = tx.QueryRow(ctx, q,
+		o.Status, o.Accrual, o.Attempts, o.NextCheckIn.Seconds(), o.ID, prevStatus).Scan(&n, &processedAt)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: order %s is no longer %s", order.ErrStatusChanged, o.ID, prevStatus)
+	}
 
-	return err
+	// событие начисления пишется в outbox вместе с переходом в PROCESSED,
+	// условие на статус гарантирует, что это происходит один раз.
+	// Время начисления берётся по часам БД, как и время записи в историю статусов
+	if o.Status == order.StatusProcessed && prevStatus != order.StatusProcessed && o.Accrual > 0 {
+		if err = webhookdb.Enqueue(ctx, tx, webhook.NewOrderAccrued(*o, processedAt)); err != nil {
+			return err
+		}
+	}
 
+	return tx.Commit(ctx)
 }
 
-func (r *repository) FindForScanner(ctx context.Context) ([]order.Order, error) {
+func (r *repository) FindForScanner(ctx context.Context, limit int, lease time.Duration) ([]order.Order, error) {
 	q := `
-		SELECT 
-			id, user_id, status, 
-			accrual, upload_at
-		FROM 
-			public.orders 
-		WHERE 
-			status = $1
-			or status = $2 
-			or status = $3
+		WITH due AS (
+			SELECT id
+			FROM public.orders
+			WHERE
+				status = ANY($1::TEXT[])
+				AND next_check_at <= CURRENT_TIMESTAMP
+			ORDER BY next_check_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE public.orders o
+		SET next_check_at = CURRENT_TIMESTAMP + make_interval(secs => $3::DOUBLE PRECISION)
+		FROM due
+		WHERE o.id = due.id
+		RETURNING 
+			o.id, o.user_id, o.status, 
+			o.accrual, o.upload_at, o.attempts
 	`
 
 	rows, err := r.client.Query(ctx, q,
-		order.StatusNew, order.StatusProcessing, order.StatusProcessed)
+		order.ActiveStatuses(), limit, lease.Seconds())
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	orders := make([]order.Order, rows.CommandTag().RowsAffected())
+	orders := make([]order.Order, 0, limit)
 
 	for rows.Next() {
 		var o order.Order
 
 		err = rows.Scan(&o.ID, &o.UserID, &o.Status,
-			&o.Accrual, &o.UploadAt)
+			&o.Accrual, &o.UploadAt, &o.Attempts)
 
 		if err != nil {
 			return nil, err
 		}
-		if o.AccrualFloat, err = strconv.ParseFloat(o.Accrual, 64); err != nil {
-			return nil, err
-		}
 
 		orders = append(orders, o)
 	}
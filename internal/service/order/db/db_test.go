@@ -0,0 +1,163 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/dbtest"
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/internal/service/user"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_ClaimConcurrent(t *testing.T) {
+	const attempts = 20
+
+	ctx, pool := dbtest.Connect(t)
+	seed := time.Now().UnixNano()
+
+	users := make([]user.User, 2)
+	for i := range users {
+		users[i] = dbtest.NewUser(ctx, t, pool, fmt.Sprintf("order-race-%d", i))
+	}
+
+	rep := NewRepository(pool, logging.GetLogger())
+	number := fmt.Sprintf("%d", seed)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed = make(map[string]int)
+		already = make(map[string]int)
+		another = make(map[string]int)
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(usrID string) {
+			defer wg.Done()
+			o := order.Order{ID: number, UserID: usrID}
+			err := rep.Claim(ctx, &o)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				claimed[usrID]++
+			case errors.Is(err, order.ErrAlreadyUploaded):
+				already[usrID]++
+			case errors.Is(err, order.ErrOwnedByAnotherUser):
+				another[usrID]++
+			default:
+				t.Errorf("unexpected error: %s", err)
+			}
+		}(users[i%2].ID)
+	}
+	wg.Wait()
+
+	require.Len(t, claimed, 1)
+	for owner, n := range claimed {
+		assert.Equal(t, 1, n)
+		assert.Equal(t, attempts/2-1, already[owner])
+		assert.Zero(t, another[owner])
+		for _, u := range users {
+			if u.ID != owner {
+				assert.Equal(t, attempts/2, another[u.ID])
+			}
+		}
+	}
+
+	o, err := rep.FindByID(ctx, number)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusNew, o.Status)
+}
+
+func TestRepository_UpdateCompareAndSet(t *testing.T) {
+	ctx, pool := dbtest.Connect(t)
+	usr := dbtest.NewUser(ctx, t, pool, "order-cas")
+
+	rep := NewRepository(pool, logging.GetLogger())
+	o := order.Order{ID: fmt.Sprintf("%d", time.Now().UnixNano()), UserID: usr.ID}
+	require.NoError(t, rep.Claim(ctx, &o))
+
+	// первый опрос переводит заказ в PROCESSING, второй с устаревшим статусом не применяется
+	first, second := o, o
+	first.Status = order.StatusProcessing
+	require.NoError(t, rep.Update(ctx, &first, order.StatusNew))
+	second.Status = order.StatusRegistered
+	assert.ErrorIs(t, rep.Update(ctx, &second, order.StatusNew), order.ErrStatusChanged)
+
+	final := first
+	final.Status, final.Accrual = order.StatusProcessed, 500
+	require.NoError(t, rep.Update(ctx, &final, order.StatusProcessing))
+
+	back := final
+	back.Status = order.StatusProcessing
+	assert.ErrorIs(t, rep.Update(ctx, &back, order.StatusProcessed), order.ErrIllegalTransition)
+
+	got, err := rep.FindByID(ctx, o.ID)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusProcessed, got.Status)
+
+	history, err := rep.History(ctx, o.ID)
+	require.NoError(t, err)
+	statuses := make([]string, 0, len(history))
+	for _, c := range history {
+		statuses = append(statuses, c.Status)
+	}
+	assert.Equal(t, []string{order.StatusNew, order.StatusProcessing, order.StatusProcessed}, statuses)
+}
+
+func TestRepository_CreateBatchOverlapping(t *testing.T) {
+	ctx, pool := dbtest.Connect(t)
+	seed := time.Now().UnixNano()
+	usr := dbtest.NewUser(ctx, t, pool, "order-batch")
+
+	rep := NewRepository(pool, logging.GetLogger())
+	numbers := make([]string, 20)
+	for i := range numbers {
+		numbers[i] = fmt.Sprintf("%d%02d", seed, i)
+	}
+
+	// пакеты с одними номерами в прямом и обратном порядке не блокируют друг друга
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(reverse bool) {
+			defer wg.Done()
+			orders := make([]*order.Order, len(numbers))
+			for j, n := range numbers {
+				k := j
+				if reverse {
+					k = len(numbers) - 1 - j
+				}
+				orders[k] = &order.Order{ID: n, UserID: usr.ID}
+			}
+			errs, err := rep.CreateBatch(ctx, orders)
+			assert.NoError(t, err)
+			assert.Len(t, errs, len(orders))
+		}(i%2 == 1)
+	}
+	wg.Wait()
+
+	for _, n := range numbers {
+		_, err := rep.FindByID(ctx, n)
+		assert.NoError(t, err)
+	}
+
+	// повтор номера в пакете получает ErrAlreadyUploaded
+	fresh := fmt.Sprintf("%d99", seed)
+	errs, err := rep.CreateBatch(ctx, []*order.Order{
+		{ID: fresh, UserID: usr.ID},
+		{ID: numbers[0], UserID: usr.ID},
+		{ID: fresh, UserID: usr.ID},
+	})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], order.ErrAlreadyUploaded)
+	assert.ErrorIs(t, errs[2], order.ErrAlreadyUploaded)
+}
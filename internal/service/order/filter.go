@@ -0,0 +1,47 @@
+package order
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nickzhog/gophermart/pkg/page"
+)
+
+var ErrBadFilter = page.ErrBadFilter
+
+// Filter ограничивает выборку заказов пользователя, нулевые поля не ограничивают.
+// Курсор страницы указывает на время загрузки и номер последнего заказа
+type Filter struct {
+	Statuses []string
+	page.Params
+}
+
+// ParseFilter разбирает параметры запроса status, from, to, after и limit
+func ParseFilter(q url.Values) (Filter, error) {
+	p, err := page.ParseParams(q)
+	if err != nil {
+		return Filter{}, err
+	}
+	f := Filter{Params: p}
+
+	for _, v := range q["status"] {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.ToUpper(strings.TrimSpace(s))
+			if !isKnownStatus(s) {
+				return Filter{}, fmt.Errorf("%w: unknown status %q", ErrBadFilter, s)
+			}
+			f.Statuses = append(f.Statuses, s)
+		}
+	}
+
+	return f, nil
+}
+
+func isKnownStatus(s string) bool {
+	switch s {
+	case StatusNew, StatusInvalid, StatusRegistered, StatusProcessing, StatusProcessed:
+		return true
+	}
+	return false
+}
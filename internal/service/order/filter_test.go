@@ -0,0 +1,72 @@
+package order
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/page"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	cursor := page.Cursor{At: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC), ID: "5880182"}
+	tests := []struct {
+		name    string
+		query   string
+		want    Filter
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  Filter{},
+		},
+		{
+			name:  "all parameters",
+			query: "status=new,processed&status=INVALID&from=2022-10-01T00:00:00Z&to=2022-10-02T00:00:00Z&limit=10&after=" + cursor.String(),
+			want: Filter{
+				Statuses: []string{StatusNew, StatusProcessed, StatusInvalid},
+				Params: page.Params{
+					From:  time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+					To:    time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC),
+					After: &cursor,
+					Limit: 10,
+				},
+			},
+		},
+		{
+			name:    "unknown status",
+			query:   "status=LOST",
+			wantErr: true,
+		},
+		{
+			name:    "bad date",
+			query:   "from=yesterday",
+			wantErr: true,
+		},
+		{
+			name:    "empty date range",
+			query:   "from=2022-10-02T00:00:00Z&to=2022-10-01T00:00:00Z",
+			wantErr: true,
+		},
+		{
+			name:    "limit too big",
+			query:   "limit=100000",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			q, err := url.ParseQuery(tt.query)
+			assert.NoError(err)
+
+			got, err := ParseFilter(q)
+			assert.Equal(tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(tt.want, got)
+			}
+		})
+	}
+}
@@ -2,24 +2,49 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/nickzhog/gophermart/internal/orderevents"
 	"github.com/nickzhog/gophermart/internal/repositories"
 	"github.com/nickzhog/gophermart/internal/service/order"
 	"github.com/nickzhog/gophermart/internal/service/user"
+	"github.com/nickzhog/gophermart/internal/web/response"
+	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/pkg/apperr"
 	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/page"
 )
 
+// heartbeat - интервал комментариев в потоке событий, чтобы прокси не закрывали соединение
+const heartbeat = time.Second * 15
+
 type handler struct {
-	logger *logging.Logger
+	logger     *logging.Logger
+	batchLimit int
+	events     orderevents.Subscriber
+	heartbeat  time.Duration
 	repositories.Repositories
 }
 
-func NewHandler(logger *logging.Logger, reps repositories.Repositories) *handler {
+type Options struct {
+	// BatchLimit наибольшее число номеров в пакетной загрузке
+	BatchLimit int
+	// Events источник событий для потока GET /orders/events
+	Events orderevents.Subscriber
+}
+
+func NewHandler(logger *logging.Logger, reps repositories.Repositories, opts Options) *handler {
 	return &handler{
 		logger:       logger,
+		batchLimit:   opts.BatchLimit,
+		events:       opts.Events,
+		heartbeat:    heartbeat,
 		Repositories: reps,
 	}
 }
@@ -27,78 +52,209 @@ func NewHandler(logger *logging.Logger, reps repositories.Repositories) *handler
 func (h *handler) GetRouteGroup() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Post("/orders", h.newOrderHandler)
+		r.Post("/orders/batch", h.newOrdersBatchHandler)
 		r.Get("/orders", h.getOrdersHandler)
+		r.Get("/orders/events", h.orderEventsHandler)
+		r.Get("/orders/{number}", h.getOrderHandler)
 	}
 }
 
-func (h *handler) writeError(w http.ResponseWriter, err string, code int) {
-	h.logger.Tracef("handler return error: %s, code: %v", err, code)
-	http.Error(w, err, code)
-}
-
-func (h *handler) writeAnswer(w http.ResponseWriter, ans string, code int) {
-	h.logger.Tracef("answer: %s, code: %v", ans, code)
-
-	w.WriteHeader(code)
-	w.Write([]byte(ans))
-}
-
 // загрузка пользователем номера заказа для расчёта
 func (h *handler) newOrderHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusBadRequest)
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
 		return
 	}
 
 	usrID := user.GetUserIDFromRequest(r)
-	order, err := order.NewOrder(string(body), usrID)
+	ord, err := order.NewOrder(string(body), usrID)
 	if err != nil {
 		h.logger.Errorf("bad order: %s, %s", string(body), err.Error())
-		h.writeError(w, err.Error(), http.StatusUnprocessableEntity)
+		response.Error(h.logger, w, r, err)
 		return
 	}
 
-	if o, err := h.Order.FindByID(r.Context(), string(body)); err == nil {
-		if o.UserID == usrID {
-			h.writeAnswer(w, "already have that order", http.StatusOK)
-			return
-		} else {
-			h.writeError(w, "order for another user", http.StatusConflict)
+	// номер занимается вставкой с ON CONFLICT без предварительного поиска,
+	// поэтому одновременная загрузка номера не приводит к нарушению первичного ключа
+	err = h.Order.Claim(r.Context(), &ord)
+	switch {
+	case errors.Is(err, order.ErrAlreadyUploaded):
+		response.Text(h.logger, w, http.StatusOK, "already have that order")
+		return
+	case err != nil:
+		// ErrOwnedByAnotherUser даёт 409
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	h.logger.Tracef("new order: %+v", ord)
+	response.Text(h.logger, w, http.StatusAccepted, "success")
+}
+
+// пакетная загрузка номеров заказов: JSON-массив строк или номера по одному в строке.
+// Корректные номера сохраняются в одной транзакции, результат возвращается по каждому номеру
+func (h *handler) newOrdersBatchHandler(w http.ResponseWriter, r *http.Request) {
+	// тело ограничивается до чтения, иначе пакет сверх лимита целиком окажется в памяти
+	r.Body = http.MaxBytesReader(w, r.Body, order.MaxBatchBytes(h.batchLimit))
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(h.logger, w, r, fmt.Errorf("%w: body exceeds %d bytes", order.ErrBatchTooLarge, tooLarge.Limit))
 			return
 		}
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
+		return
 	}
-
-	err = h.Order.Create(r.Context(), &order)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	numbers, err := order.ParseBatch(body, mediaType == "application/json", h.batchLimit)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, err)
 		return
 	}
 
-	h.logger.Tracef("new order: %+v", order)
-	h.writeAnswer(w, "success", http.StatusAccepted)
+	usrID := user.GetUserIDFromRequest(r)
+	results := make([]order.BatchResult, len(numbers))
+	orders := make([]*order.Order, 0, len(numbers))
+	valid := make([]int, 0, len(numbers))
+	for i, n := range numbers {
+		ord, err := order.NewOrder(n, usrID)
+		if err != nil {
+			results[i] = order.NewBatchResult(n, err)
+			continue
+		}
+		orders = append(orders, &ord)
+		valid = append(valid, i)
+	}
+
+	if len(orders) > 0 {
+		errs, err := h.Order.CreateBatch(r.Context(), orders)
+		if err != nil {
+			response.Error(h.logger, w, r, err)
+			return
+		}
+		for j, i := range valid {
+			results[i] = order.NewBatchResult(orders[j].ID, errs[j])
+		}
+	}
+
+	h.logger.Tracef("orders batch of user %s: %+v", usrID, results)
+	response.JSON(h.logger, w, r, http.StatusOK, results)
 }
 
 // получение списка загруженных пользователем номеров заказов, статусов их обработки и информации о начислениях
 func (h *handler) getOrdersHandler(w http.ResponseWriter, r *http.Request) {
 	usrID := user.GetUserIDFromRequest(r)
 
-	orders, err := h.Order.FindForUser(r.Context(), usrID)
+	filter, err := order.ParseFilter(r.URL.Query())
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	// запрашиваем на один заказ больше, чтобы узнать, есть ли следующая страница
+	query := filter
+	if query.Limit > 0 {
+		query.Limit++
+	}
+	orders, err := h.Order.FindForUser(r.Context(), usrID, query)
 	if err != nil && err != order.ErrNoRows {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, err)
 		return
 	}
 	if len(orders) < 1 {
-		h.writeAnswer(w, "no orders", http.StatusNoContent)
+		response.Text(h.logger, w, http.StatusNoContent, "no orders")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	data, err := json.Marshal(orders)
+	if filter.Limit > 0 && len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+		last := orders[len(orders)-1]
+		response.SetNextPage(w, r, page.Cursor{At: last.UploadAt, ID: last.ID}.String())
+	}
+
+	response.JSON(h.logger, w, r, http.StatusOK, orders)
+}
+
+// получение заказа пользователя с историей смены статусов
+func (h *handler) getOrderHandler(w http.ResponseWriter, r *http.Request) {
+	number, err := order.ParseNumber(chi.URLParam(r, "number"))
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, err)
 		return
 	}
 
-	h.writeAnswer(w, string(data), http.StatusOK)
+	usrID := user.GetUserIDFromRequest(r)
+	ord, err := h.Order.FindByID(r.Context(), number)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	// чужой заказ неотличим от несуществующего
+	if ord.UserID != usrID {
+		response.Error(h.logger, w, r, order.ErrNoRows)
+		return
+	}
+
+	history, err := h.Order.History(r.Context(), number)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	response.JSON(h.logger, w, r, http.StatusOK, order.Details{Order: ord, History: history})
+}
+
+// поток Server-Sent Events об изменениях заказов пользователя
+func (h *handler) orderEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || h.events == nil {
+		response.Error(h.logger, w, r, apperr.New(apperr.ErrNotImplemented, "event stream is not supported"))
+		return
+	}
+
+	usrID := user.GetUserIDFromRequest(r)
+	sID := session.GetSessionIDFromRequest(r)
+	events, unsubscribe := h.events.Subscribe(usrID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			// сессия проверяется только при подключении, поэтому поток закрывается,
+			// если её завершили или она истекла, пока он был открыт. Проверка не продлевает
+			// сессию, иначе открытая вкладка держала бы её до абсолютного таймаута
+			if err := h.Session.Check(r.Context(), sID); err != nil {
+				h.logger.Tracef("order events stream of user %s closed: %s", usrID, err.Error())
+				return
+			}
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				// источник событий остановлен, например при завершении сервера
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				h.logger.Errorf("cant encode order event: %s", err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "event: order\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
 }
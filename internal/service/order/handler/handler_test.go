@@ -1,27 +1,33 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
 	"context"
-	"errors"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
+	"github.com/nickzhog/gophermart/internal/orderevents"
 	"github.com/nickzhog/gophermart/internal/service/order"
 	mock_order "github.com/nickzhog/gophermart/internal/service/order/mocks"
 	"github.com/nickzhog/gophermart/internal/web/session"
+	mock_session "github.com/nickzhog/gophermart/internal/web/session/mocks"
 	"github.com/nickzhog/gophermart/pkg/logging"
 	"github.com/stretchr/testify/assert"
 )
 
 const (
-	validOrderID   = "5880182"
-	validOrderID2  = "71476808630764"
-	validUserID    = "ValidID"
-	validSessionID = "SessionID"
+	validOrderID          = "5880182"
+	validOrderID2         = "71476808630764"
+	ownedByAnotherOrderID = "79927398713"
+	validUserID           = "ValidID"
+	validSessionID        = "SessionID"
 )
 
 func prepareNewOrderHandler(ctrl *gomock.Controller) *handler {
@@ -30,16 +36,14 @@ func prepareNewOrderHandler(ctrl *gomock.Controller) *handler {
 	}
 
 	orderRep := mock_order.NewMockRepository(ctrl)
-	orderRep.EXPECT().FindByID(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, id string) (order.Order, error) {
-			if id == validOrderID {
-				return order.Order{ID: validOrderID, UserID: validUserID, Status: order.StatusNew, UploadAt: time.Now()}, nil
+	orderRep.EXPECT().Claim(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, o *order.Order) error {
+			switch o.ID {
+			case validOrderID:
+				return order.ErrAlreadyUploaded
+			case ownedByAnotherOrderID:
+				return order.ErrOwnedByAnotherUser
 			}
-			return order.Order{}, errors.New("not found")
-		})
-
-	orderRep.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, order *order.Order) error {
 			return nil
 		})
 	h.Repositories.Order = orderRep
@@ -62,6 +66,11 @@ func Test_handler_newOrderHandler(t *testing.T) {
 			requestBody: []byte(validOrderID2),
 			wantStatus:  http.StatusAccepted,
 		},
+		{
+			name:        "order of another user",
+			requestBody: []byte(ownedByAnotherOrderID),
+			wantStatus:  http.StatusConflict,
+		},
 		{
 			name:        "wrong order (luhn)",
 			requestBody: []byte(`1234`),
@@ -97,16 +106,26 @@ func prepareOrdersListHandler(ctrl *gomock.Controller) *handler {
 	}
 
 	orderRep := mock_order.NewMockRepository(ctrl)
-	orderRep.EXPECT().FindForUser(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, usrID string) ([]order.Order, error) {
-			if usrID == validUserID {
-				return []order.Order{
-					{ID: validOrderID, UserID: usrID, Status: order.StatusNew, UploadAt: time.Now()},
-					{ID: validOrderID2, UserID: usrID, Status: order.StatusNew, UploadAt: time.Now()},
-				}, nil
+	orderRep.EXPECT().FindForUser(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID string, filter order.Filter) ([]order.Order, error) {
+			if usrID != validUserID {
+				return []order.Order{}, order.ErrNoRows
 			}
 
-			return []order.Order{}, order.ErrNoRows
+			uploadAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+			orders := []order.Order{
+				{ID: validOrderID, UserID: usrID, Status: order.StatusNew, UploadAt: uploadAt},
+				{ID: validOrderID2, UserID: usrID, Status: order.StatusNew, UploadAt: uploadAt.Add(time.Minute)},
+			}
+			if filter.After != nil {
+				for len(orders) > 0 && !orders[0].UploadAt.After(filter.After.At) {
+					orders = orders[1:]
+				}
+			}
+			if filter.Limit > 0 && len(orders) > filter.Limit {
+				orders = orders[:filter.Limit]
+			}
+			return orders, nil
 		})
 
 	h.Repositories.Order = orderRep
@@ -118,12 +137,43 @@ func Test_handler_getOrdersHandler(t *testing.T) {
 	tests := []struct {
 		name       string
 		usrID      string
+		query      string
 		wantStatus int
+		wantOrders int
+		wantNext   bool
 	}{
 		{
 			name:       "positive case",
 			usrID:      validUserID,
 			wantStatus: http.StatusOK,
+			wantOrders: 2,
+		},
+		{
+			name:       "first page",
+			usrID:      validUserID,
+			query:      "?limit=1",
+			wantStatus: http.StatusOK,
+			wantOrders: 1,
+			wantNext:   true,
+		},
+		{
+			name:       "last page",
+			usrID:      validUserID,
+			query:      "?limit=2",
+			wantStatus: http.StatusOK,
+			wantOrders: 2,
+		},
+		{
+			name:       "unknown status",
+			usrID:      validUserID,
+			query:      "?status=LOST",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad limit",
+			usrID:      validUserID,
+			query:      "?limit=0",
+			wantStatus: http.StatusBadRequest,
 		},
 		{
 			name:       "user without orders",
@@ -140,7 +190,7 @@ func Test_handler_getOrdersHandler(t *testing.T) {
 
 			h := prepareOrdersListHandler(ctrl)
 
-			request := httptest.NewRequest(http.MethodGet, "/api/user/orders", bytes.NewBuffer(nil))
+			request := httptest.NewRequest(http.MethodGet, "/api/user/orders"+tt.query, bytes.NewBuffer(nil))
 			request = session.PutSessionDataInRequest(request, validSessionID, tt.usrID)
 
 			w := httptest.NewRecorder()
@@ -150,6 +200,304 @@ func Test_handler_getOrdersHandler(t *testing.T) {
 			defer res.Body.Close()
 
 			assert.Equal(tt.wantStatus, res.StatusCode)
+			assert.Equal(tt.wantNext, res.Header.Get("X-Next-Cursor") != "")
+			assert.Equal(tt.wantNext, res.Header.Get("Link") != "")
+			if res.StatusCode == http.StatusOK {
+				var orders []order.Order
+				assert.NoError(json.NewDecoder(res.Body).Decode(&orders))
+				assert.Len(orders, tt.wantOrders)
+			}
 		})
 	}
 }
+
+func prepareOrdersBatchHandler(ctrl *gomock.Controller) *handler {
+	h := &handler{
+		logger:     logging.GetLogger(),
+		batchLimit: 3,
+	}
+
+	orderRep := mock_order.NewMockRepository(ctrl)
+	orderRep.EXPECT().CreateBatch(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, orders []*order.Order) ([]error, error) {
+			errs := make([]error, len(orders))
+			for i, o := range orders {
+				switch o.ID {
+				case validOrderID:
+					errs[i] = order.ErrAlreadyUploaded
+				case ownedByAnotherOrderID:
+					errs[i] = order.ErrOwnedByAnotherUser
+				}
+			}
+			return errs, nil
+		})
+	h.Repositories.Order = orderRep
+
+	return h
+}
+
+func Test_handler_newOrdersBatchHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		requestBody string
+		wantStatus  int
+		want        []order.BatchResult
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			requestBody: `["5880182", "71476808630764", "79927398713"]`,
+			wantStatus:  http.StatusOK,
+			want: []order.BatchResult{
+				{Number: validOrderID, Result: order.BatchAlreadyUploaded},
+				{Number: validOrderID2, Result: order.BatchAccepted},
+				{Number: ownedByAnotherOrderID, Result: order.BatchOwnedByAnotherUser},
+			},
+		},
+		{
+			name:        "text",
+			contentType: "text/plain",
+			requestBody: "1234\n\n 71476808630764\r\n",
+			wantStatus:  http.StatusOK,
+			want: []order.BatchResult{
+				{Number: "1234", Result: order.BatchInvalid, Error: "invalid order number: luhn check fail"},
+				{Number: validOrderID2, Result: order.BatchAccepted},
+			},
+		},
+		{
+			name:        "only invalid",
+			contentType: "text/plain",
+			requestBody: "abc",
+			wantStatus:  http.StatusOK,
+			want: []order.BatchResult{
+				{Number: "abc", Result: order.BatchInvalid, Error: "invalid order number: number must contain only digits"},
+			},
+		},
+		{
+			name:        "over limit",
+			contentType: "text/plain",
+			requestBody: "5880182\n5880182\n5880182\n5880182",
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "body over limit",
+			contentType: "text/plain",
+			requestBody: "5880182" + strings.Repeat(" ", 1000),
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "bad json",
+			contentType: "application/json",
+			requestBody: `[5880182]`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "empty",
+			contentType: "text/plain",
+			requestBody: "\n",
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			h := prepareOrdersBatchHandler(ctrl)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", bytes.NewBufferString(tt.requestBody))
+			request.Header.Set("Content-Type", tt.contentType)
+			request = session.PutSessionDataInRequest(request, validSessionID, validUserID)
+
+			w := httptest.NewRecorder()
+			handler := http.HandlerFunc(h.newOrdersBatchHandler)
+			handler.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+			if res.StatusCode == http.StatusOK {
+				var got []order.BatchResult
+				assert.NoError(json.NewDecoder(res.Body).Decode(&got))
+				assert.Equal(tt.want, got)
+			}
+		})
+	}
+}
+
+func prepareOrderHandler(ctrl *gomock.Controller) *handler {
+	h := &handler{
+		logger: logging.GetLogger(),
+	}
+
+	uploadAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	orderRep := mock_order.NewMockRepository(ctrl)
+	orderRep.EXPECT().FindByID(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, id string) (order.Order, error) {
+			switch id {
+			case validOrderID:
+				return order.Order{ID: id, UserID: validUserID, Status: order.StatusProcessed, Accrual: 500, UploadAt: uploadAt}, nil
+			case ownedByAnotherOrderID:
+				return order.Order{ID: id, UserID: "another", Status: order.StatusNew, UploadAt: uploadAt}, nil
+			}
+			return order.Order{}, order.ErrNoRows
+		})
+	orderRep.EXPECT().History(gomock.Any(), validOrderID).AnyTimes().
+		Return([]order.StatusChange{
+			{Status: order.StatusNew, ChangedAt: uploadAt},
+			{Status: order.StatusProcessing, ChangedAt: uploadAt.Add(time.Second)},
+			{Status: order.StatusProcessed, Accrual: 500, ChangedAt: uploadAt.Add(time.Second * 2)},
+		}, nil)
+	h.Repositories.Order = orderRep
+
+	return h
+}
+
+func Test_handler_getOrderHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		number      string
+		wantStatus  int
+		wantHistory []string
+	}{
+		{
+			name:        "positive case",
+			number:      validOrderID,
+			wantStatus:  http.StatusOK,
+			wantHistory: []string{order.StatusNew, order.StatusProcessing, order.StatusProcessed},
+		},
+		{
+			name:       "order of another user",
+			number:     ownedByAnotherOrderID,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown order",
+			number:     validOrderID2,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "wrong number",
+			number:     "1234",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			h := prepareOrderHandler(ctrl)
+			router := chi.NewRouter()
+			router.Group(h.GetRouteGroup())
+
+			request := httptest.NewRequest(http.MethodGet, "/orders/"+tt.number, nil)
+			request = session.PutSessionDataInRequest(request, validSessionID, validUserID)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+			if res.StatusCode == http.StatusOK {
+				var details order.Details
+				assert.NoError(json.NewDecoder(res.Body).Decode(&details))
+				assert.Equal(tt.number, details.ID)
+				assert.Equal(order.StatusProcessed, details.Status)
+				statuses := make([]string, 0, len(details.History))
+				for _, c := range details.History {
+					statuses = append(statuses, c.Status)
+				}
+				assert.Equal(tt.wantHistory, statuses)
+			}
+		})
+	}
+}
+
+// openOrderEvents открывает поток событий, sessionErr возвращается при проверке сессии.
+// next возвращает следующую строку потока без пустых строк и пингов, "" после его закрытия
+func openOrderEvents(t *testing.T, broker *orderevents.Broker, sessionErr error) (res *http.Response, next func() string) {
+	ctrl := gomock.NewController(t)
+	sessionRep := mock_session.NewMockRepository(ctrl)
+	// поток проверяет сессию без продления: вызов FindByID провалил бы тест
+	sessionRep.EXPECT().Check(gomock.Any(), validSessionID).AnyTimes().Return(sessionErr)
+
+	h := &handler{
+		logger:    logging.GetLogger(),
+		events:    broker,
+		heartbeat: time.Millisecond * 20,
+	}
+	h.Repositories.Session = sessionRep
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.orderEventsHandler(w, session.PutSessionDataInRequest(r, validSessionID, validUserID))
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	lines := bufio.NewScanner(res.Body)
+	next = func() string {
+		for lines.Scan() {
+			if l := lines.Text(); l != "" && l != ": ping" {
+				return l
+			}
+		}
+		return ""
+	}
+	return res, next
+}
+
+func Test_handler_orderEventsHandler(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	broker := orderevents.NewBroker(logging.GetLogger())
+	res, next := openOrderEvents(t, broker, nil)
+
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("text/event-stream", res.Header.Get("Content-Type"))
+	assert.Equal(": connected", next())
+
+	// событие другого пользователя в поток не попадает
+	broker.Publish(ctx, orderevents.Event{Number: validOrderID2, UserID: "another", Status: order.StatusProcessed})
+	broker.Publish(ctx, orderevents.Event{Number: validOrderID, UserID: validUserID, Status: order.StatusProcessed, Accrual: 500})
+	assert.Equal("event: order", next())
+
+	var e orderevents.Event
+	assert.NoError(json.Unmarshal([]byte(strings.TrimPrefix(next(), "data: ")), &e))
+	assert.Equal(validOrderID, e.Number)
+	assert.Equal(order.StatusProcessed, e.Status)
+
+	// остановка брокера завершает поток
+	broker.Close()
+	assert.Equal("", next())
+}
+
+func Test_handler_orderEventsHandler_sessionRevoked(t *testing.T) {
+	assert := assert.New(t)
+
+	broker := orderevents.NewBroker(logging.GetLogger())
+	defer broker.Close()
+	_, next := openOrderEvents(t, broker, session.ErrNoRows)
+
+	assert.Equal(": connected", next())
+	// первая же проверка сессии в пинге закрывает поток
+	assert.Equal("", next())
+}
@@ -1,35 +1,69 @@
 package order
 
-// CalculateLuhn return the check number
-func CalculateLuhn(number int) int {
-	checkNumber := checksum(number)
+import (
+	"fmt"
+	"strings"
+)
 
-	if checkNumber == 0 {
-		return 0
+// ParseNumber проверяет номер заказа: последовательность цифр произвольной длины,
+// проходящая проверку Луна. Пробельные символы по краям отбрасываются,
+// ведущие нули сохраняются и являются частью номера.
+func ParseNumber(s string) (string, error) {
+	number := strings.TrimSpace(s)
+	if number == "" {
+		return "", fmt.Errorf("%w: empty number", ErrInvalidNumber)
 	}
-	return 10 - checkNumber
+	if !isDigits(number) {
+		return "", fmt.Errorf("%w: number must contain only digits", ErrInvalidNumber)
+	}
+	if strings.Trim(number, "0") == "" {
+		return "", fmt.Errorf("%w: number must not be zero", ErrInvalidNumber)
+	}
+	if !ValidLuhn(number) {
+		return "", fmt.Errorf("%w: luhn check fail", ErrInvalidNumber)
+	}
+	return number, nil
 }
 
-// Valid check number is valid or not based on Luhn algorithm
-func ValidLuhn(number int) bool {
-	return (number%10+checksum(number/10))%10 == 0
+// CalculateLuhn возвращает контрольную цифру, которую нужно дописать к number
+func CalculateLuhn(number string) (int, error) {
+	if number == "" || !isDigits(number) {
+		return 0, fmt.Errorf("%w: number must contain only digits", ErrInvalidNumber)
+	}
+	// контрольная цифра окажется справа, поэтому удваивается последняя цифра number
+	return (10 - checksum(number, 0)) % 10, nil
 }
 
-func checksum(number int) int {
-	var luhn int
-
-	for i := 0; number > 0; i++ {
-		cur := number % 10
+// ValidLuhn проверяет последнюю цифру number как контрольную по алгоритму Луна
+func ValidLuhn(number string) bool {
+	if number == "" || !isDigits(number) {
+		return false
+	}
+	return checksum(number, 1) == 0
+}
 
-		if i%2 == 0 { // even
-			cur = cur * 2
-			if cur > 9 {
-				cur = cur%10 + cur/10
+// checksum считает сумму Луна по модулю 10, удваивая цифры на позициях
+// с чётностью parity, позиции считаются справа от нуля
+func checksum(number string, parity int) int {
+	var sum int
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == parity {
+			d *= 2
+			if d > 9 {
+				d -= 9
 			}
 		}
+		sum += d
+	}
+	return sum % 10
+}
 
-		luhn += cur
-		number = number / 10
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
 	}
-	return luhn % 10
+	return true
 }
@@ -0,0 +1,105 @@
+package order
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// digits - произвольная непустая последовательность цифр для проверки свойств
+type digits string
+
+func (digits) Generate(r *rand.Rand, size int) reflect.Value {
+	b := make([]byte, 1+r.Intn(size+40))
+	for i := range b {
+		b[i] = byte('0' + r.Intn(10))
+	}
+	return reflect.ValueOf(digits(b))
+}
+
+func withCheckDigit(t *testing.T, number string) string {
+	d, err := CalculateLuhn(number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return number + strconv.Itoa(d)
+}
+
+func TestValidLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{number: "79927398713", want: true},
+		{number: "79927398710", want: false},
+		{number: "5880182", want: true},
+		{number: "71476808630764", want: true},
+		{number: "123456789012345678901234567891", want: true},
+		{number: "123456789012345678901234567890", want: false},
+		{number: "", want: false},
+		{number: "-5880182", want: false},
+		{number: "5880182 ", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidLuhn(tt.number))
+		})
+	}
+}
+
+func TestCalculateLuhn(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := CalculateLuhn("7992739871")
+	assert.NoError(err)
+	assert.Equal(3, d)
+
+	_, err = CalculateLuhn("")
+	assert.ErrorIs(err, ErrInvalidNumber)
+	_, err = CalculateLuhn("79a")
+	assert.ErrorIs(err, ErrInvalidNumber)
+}
+
+func TestLuhnProperties(t *testing.T) {
+	properties := map[string]interface{}{
+		// дописанная контрольная цифра всегда даёт корректный номер
+		"check digit makes number valid": func(n digits) bool {
+			return ValidLuhn(withCheckDigit(t, string(n)))
+		},
+		// контрольная цифра единственна
+		"other check digits are invalid": func(n digits) bool {
+			valid := withCheckDigit(t, string(n))
+			for d := '0'; d <= '9'; d++ {
+				number := string(n) + string(d)
+				if number != valid && ValidLuhn(number) {
+					return false
+				}
+			}
+			return true
+		},
+		// замена любой одной цифры обнаруживается
+		"single digit error is detected": func(n digits, pos uint, delta uint8) bool {
+			number := []byte(withCheckDigit(t, string(n)))
+			i := int(pos % uint(len(number)))
+			number[i] = '0' + (number[i]-'0'+1+delta%9)%10
+			return !ValidLuhn(string(number))
+		},
+		// ведущие нули не меняют результат проверки
+		"leading zeros keep validity": func(n digits, zeros uint8) bool {
+			number := withCheckDigit(t, string(n))
+			return ValidLuhn(strings.Repeat("0", int(zeros%20))+number) == ValidLuhn(number)
+		},
+	}
+	for name, f := range properties {
+		t.Run(name, func(t *testing.T) {
+			if err := quick.Check(f, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
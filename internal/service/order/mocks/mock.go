@@ -7,6 +7,7 @@ package mock_order
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	order "github.com/nickzhog/gophermart/internal/service/order"
@@ -35,18 +36,33 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// Create mocks base method.
-func (m *MockRepository) Create(ctx context.Context, o *order.Order) error {
+// Claim mocks base method.
+func (m *MockRepository) Claim(ctx context.Context, o *order.Order) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", ctx, o)
+	ret := m.ctrl.Call(m, "Claim", ctx, o)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Create indicates an expected call of Create.
-func (mr *MockRepositoryMockRecorder) Create(ctx, o interface{}) *gomock.Call {
+// Claim indicates an expected call of Claim.
+func (mr *MockRepositoryMockRecorder) Claim(ctx, o interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), ctx, o)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*MockRepository)(nil).Claim), ctx, o)
+}
+
+// CreateBatch mocks base method.
+func (m *MockRepository) CreateBatch(ctx context.Context, orders []*order.Order) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", ctx, orders)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockRepositoryMockRecorder) CreateBatch(ctx, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockRepository)(nil).CreateBatch), ctx, orders)
 }
 
 // FindByID mocks base method.
@@ -65,45 +81,60 @@ func (mr *MockRepositoryMockRecorder) FindByID(ctx, id interface{}) *gomock.Call
 }
 
 // FindForScanner mocks base method.
-func (m *MockRepository) FindForScanner(ctx context.Context) ([]order.Order, error) {
+func (m *MockRepository) FindForScanner(ctx context.Context, limit int, lease time.Duration) ([]order.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindForScanner", ctx)
+	ret := m.ctrl.Call(m, "FindForScanner", ctx, limit, lease)
 	ret0, _ := ret[0].([]order.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindForScanner indicates an expected call of FindForScanner.
-func (mr *MockRepositoryMockRecorder) FindForScanner(ctx interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FindForScanner(ctx, limit, lease interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForScanner", reflect.TypeOf((*MockRepository)(nil).FindForScanner), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForScanner", reflect.TypeOf((*MockRepository)(nil).FindForScanner), ctx, limit, lease)
 }
 
 // FindForUser mocks base method.
-func (m *MockRepository) FindForUser(ctx context.Context, usrID string) ([]order.Order, error) {
+func (m *MockRepository) FindForUser(ctx context.Context, usrID string, filter order.Filter) ([]order.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindForUser", ctx, usrID)
+	ret := m.ctrl.Call(m, "FindForUser", ctx, usrID, filter)
 	ret0, _ := ret[0].([]order.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindForUser indicates an expected call of FindForUser.
-func (mr *MockRepositoryMockRecorder) FindForUser(ctx, usrID interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FindForUser(ctx, usrID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForUser", reflect.TypeOf((*MockRepository)(nil).FindForUser), ctx, usrID, filter)
+}
+
+// History mocks base method.
+func (m *MockRepository) History(ctx context.Context, id string) ([]order.StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", ctx, id)
+	ret0, _ := ret[0].([]order.StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockRepositoryMockRecorder) History(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForUser", reflect.TypeOf((*MockRepository)(nil).FindForUser), ctx, usrID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockRepository)(nil).History), ctx, id)
 }
 
 // Update mocks base method.
-func (m *MockRepository) Update(ctx context.Context, o *order.Order) error {
+func (m *MockRepository) Update(ctx context.Context, o *order.Order, prevStatus string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", ctx, o)
+	ret := m.ctrl.Call(m, "Update", ctx, o, prevStatus)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Update indicates an expected call of Update.
-func (mr *MockRepositoryMockRecorder) Update(ctx, o interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Update(ctx, o, prevStatus interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), ctx, o)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), ctx, o, prevStatus)
 }
@@ -1,10 +1,11 @@
 package order
 
 import (
-	"errors"
 	"fmt"
-	"strconv"
 	"time"
+
+	"github.com/nickzhog/gophermart/pkg/apperr"
+	"github.com/nickzhog/gophermart/pkg/money"
 )
 
 const (
@@ -15,45 +16,80 @@ const (
 	StatusProcessed  = "PROCESSED"  // расчёт начисления окончен
 )
 
-var ErrNoRows = errors.New("order not found")
+var (
+	ErrNoRows             = apperr.New(apperr.ErrNotFound, "order not found")
+	ErrInvalidNumber      = apperr.New(apperr.ErrValidation, "invalid order number")
+	ErrOwnedByAnotherUser = apperr.New(apperr.ErrConflict, "order uploaded by another user")
+)
 
 type Order struct {
-	ID           string    `json:"number"`
-	UserID       string    `json:"-"`
-	Status       string    `json:"status"`
-	Accrual      string    `json:"-"`
-	AccrualFloat float64   `json:"accrual"`
-	UploadAt     time.Time `json:"uploaded_at"`
+	ID       string       `json:"number"`
+	UserID   string       `json:"-"`
+	Status   string       `json:"status"`
+	Accrual  money.Amount `json:"accrual"`
+	UploadAt time.Time    `json:"uploaded_at"`
+	Attempts int          `json:"-"`
+	// NextCheckIn задержка до следующей проверки, сам момент проверки считает БД по своим часам
+	NextCheckIn time.Duration `json:"-"`
+}
+
+// StatusChange переход заказа в новый статус
+type StatusChange struct {
+	Status    string       `json:"status"`
+	Accrual   money.Amount `json:"accrual,omitempty"`
+	ChangedAt time.Time    `json:"changed_at"`
+}
+
+// Details заказ вместе с историей его статусов по времени
+type Details struct {
+	Order
+	History []StatusChange `json:"history"`
 }
 
 func NewOrder(id, usrID string) (Order, error) {
-	if len(id) < 1 || len(usrID) < 1 {
-		return Order{}, fmt.Errorf("empty data: id(%s), usrID(%s)", id, usrID)
+	if len(usrID) < 1 {
+		return Order{}, fmt.Errorf("%w: empty data: id(%s), usrID(%s)", ErrInvalidNumber, id, usrID)
 	}
 
-	idInt, err := strconv.Atoi(id)
+	number, err := ParseNumber(id)
 	if err != nil {
 		return Order{}, err
 	}
-	if !ValidLuhn(idInt) {
-		return Order{}, errors.New("luhn check fail")
-	}
 
 	o := Order{
-		ID:      id,
-		UserID:  usrID,
-		Accrual: "0.0",
+		ID:     number,
+		UserID: usrID,
 	}
 	return o, nil
 }
 
-func AccrualSumForProcessedOrders(ords []Order) float64 {
-	ans := 0.0
+func AccrualSumForProcessedOrders(ords []Order) money.Amount {
+	var ans money.Amount
 	for _, o := range ords {
 		if o.Status != StatusProcessed {
 			continue
 		}
-		ans += o.AccrualFloat
+		ans += o.Accrual
 	}
 	return ans
 }
+
+// ScheduleNextCheck откладывает следующую проверку заказа в системе начислений.
+// Пока статус не меняется, задержка растёт экспоненциально от base до max.
+func (o *Order) ScheduleNextCheck(base, max time.Duration) {
+	delay := base
+	for i := 0; i < o.Attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	o.Attempts++
+	o.NextCheckIn = delay
+}
+
+// ResetSchedule назначает немедленную проверку заказа, например после смены статуса
+func (o *Order) ResetSchedule() {
+	o.Attempts = 0
+	o.NextCheckIn = 0
+}
@@ -2,7 +2,9 @@ package order
 
 import (
 	"testing"
+	"time"
 
+	"github.com/nickzhog/gophermart/pkg/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -10,33 +12,33 @@ func TestAccrualSumForProcessedOrders(t *testing.T) {
 	tests := []struct {
 		name string
 		ords []Order
-		want float64
+		want money.Amount
 	}{
 		{
 			name: "normal case",
 			ords: []Order{
-				{Status: StatusProcessed, AccrualFloat: 10},
-				{Status: StatusProcessed, AccrualFloat: 10},
+				{Status: StatusProcessed, Accrual: 1000},
+				{Status: StatusProcessed, Accrual: 1000},
 			},
-			want: 20,
+			want: 2000,
 		},
 		{
 			name: "another status",
 			ords: []Order{
-				{Status: StatusProcessing, AccrualFloat: 10},
-				{Status: StatusNew, AccrualFloat: 10},
+				{Status: StatusProcessing, Accrual: 1000},
+				{Status: StatusNew, Accrual: 1000},
 			},
 			want: 0,
 		},
 		{
 			name: "processed and another status",
 			ords: []Order{
-				{Status: StatusProcessed, AccrualFloat: 10},
-				{Status: StatusProcessed, AccrualFloat: 10},
-				{Status: StatusInvalid, AccrualFloat: 10},
-				{Status: StatusRegistered, AccrualFloat: 10},
+				{Status: StatusProcessed, Accrual: 1000},
+				{Status: StatusProcessed, Accrual: 1000},
+				{Status: StatusInvalid, Accrual: 1000},
+				{Status: StatusRegistered, Accrual: 1000},
 			},
-			want: 20,
+			want: 2000,
 		},
 	}
 	for _, tt := range tests {
@@ -56,13 +58,48 @@ func TestNewOrder(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
+		want    string
 		wantErr bool
 	}{
 		{
 			name:    "positive case",
 			args:    args{id: "485542211", usrID: "usrID"},
+			want:    "485542211",
 			wantErr: false,
 		},
+		{
+			name:    "longer than int64",
+			args:    args{id: "123456789012345678901234567891", usrID: "usrID"},
+			want:    "123456789012345678901234567891",
+			wantErr: false,
+		},
+		{
+			name:    "surrounding whitespace",
+			args:    args{id: " 485542211\r\n", usrID: "usrID"},
+			want:    "485542211",
+			wantErr: false,
+		},
+		{
+			name:    "leading zeros are kept",
+			args:    args{id: "00005880182", usrID: "usrID"},
+			want:    "00005880182",
+			wantErr: false,
+		},
+		{
+			name:    "sign",
+			args:    args{id: "+485542211", usrID: "usrID"},
+			wantErr: true,
+		},
+		{
+			name:    "inner whitespace",
+			args:    args{id: "4855 42211", usrID: "usrID"},
+			wantErr: true,
+		},
+		{
+			name:    "zero",
+			args:    args{id: "000", usrID: "usrID"},
+			wantErr: true,
+		},
 		{
 			name:    "wrong luhn orderID case",
 			args:    args{id: "1233211", usrID: "usrID"},
@@ -77,8 +114,34 @@ func TestNewOrder(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			assert := assert.New(t)
-			_, err := NewOrder(tt.args.id, tt.args.usrID)
+			got, err := NewOrder(tt.args.id, tt.args.usrID)
 			assert.Equal(tt.wantErr, err != nil)
+			assert.Equal(tt.want, got.ID)
+			if err != nil {
+				assert.ErrorIs(err, ErrInvalidNumber)
+			}
+		})
+	}
+}
+
+func TestOrder_ScheduleNextCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		attempts  int
+		wantDelay time.Duration
+	}{
+		{name: "first check", attempts: 0, wantDelay: time.Second},
+		{name: "third check", attempts: 2, wantDelay: time.Second * 4},
+		{name: "capped by max", attempts: 10, wantDelay: time.Minute},
+		{name: "no overflow", attempts: 1000, wantDelay: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			o := Order{Attempts: tt.attempts}
+			o.ScheduleNextCheck(time.Second, time.Minute)
+			assert.Equal(tt.attempts+1, o.Attempts)
+			assert.Equal(tt.wantDelay, o.NextCheckIn)
 		})
 	}
 }
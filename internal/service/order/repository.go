@@ -1,11 +1,27 @@
 package order
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Repository interface {
-	Create(ctx context.Context, o *Order) error
+	// Claim атомарно сохраняет заказ, если номер ещё не загружен. Если загружен,
+	// возвращает ErrAlreadyUploaded или ErrOwnedByAnotherUser в зависимости от владельца
+	Claim(ctx context.Context, o *Order) error
+	// CreateBatch сохраняет заказы в одной транзакции. Для каждого заказа возвращает nil,
+	// если он создан, или ErrAlreadyUploaded и ErrOwnedByAnotherUser, если номер уже загружен
+	CreateBatch(ctx context.Context, orders []*Order) ([]error, error)
 	FindByID(ctx context.Context, id string) (Order, error)
-	FindForUser(ctx context.Context, usrID string) ([]Order, error)
-	FindForScanner(ctx context.Context) ([]Order, error)
-	Update(ctx context.Context, o *Order) error
+	// History возвращает переходы статусов заказа по возрастанию времени
+	History(ctx context.Context, id string) ([]StatusChange, error)
+	// FindForUser возвращает заказы пользователя по возрастанию времени загрузки
+	FindForUser(ctx context.Context, usrID string, filter Filter) ([]Order, error)
+	// FindForScanner захватывает до limit заказов в неокончательных статусах, время проверки которых наступило,
+	// и откладывает их следующую проверку на lease, чтобы другие экземпляры их пропустили
+	FindForScanner(ctx context.Context, limit int, lease time.Duration) ([]Order, error)
+	// Update сохраняет заказ, только если его статус в БД всё ещё prevStatus, иначе возвращает
+	// ErrStatusChanged. Недопустимый переход из prevStatus даёт ErrIllegalTransition.
+	// Смена статуса записывается в историю, начисление баллов - в outbox вебхуков
+	Update(ctx context.Context, o *Order, prevStatus string) error
 }
@@ -0,0 +1,69 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/nickzhog/gophermart/pkg/apperr"
+)
+
+var (
+	ErrIllegalTransition = apperr.New(apperr.ErrConflict, "illegal order status transition")
+	// ErrStatusChanged статус заказа изменился с момента чтения, например другим экземпляром сервиса
+	ErrStatusChanged = apperr.New(apperr.ErrConflict, "order status changed concurrently")
+)
+
+// transitions допустимые переходы статусов. Статусы без переходов окончательные
+var transitions = map[string][]string{
+	StatusNew:        {StatusRegistered, StatusProcessing, StatusProcessed, StatusInvalid},
+	StatusRegistered: {StatusProcessing, StatusProcessed, StatusInvalid},
+	StatusProcessing: {StatusProcessed, StatusInvalid},
+	StatusProcessed:  nil,
+	StatusInvalid:    nil,
+}
+
+// statusOrder порядок статусов для ActiveStatuses
+var statusOrder = []string{StatusNew, StatusRegistered, StatusProcessing, StatusProcessed, StatusInvalid}
+
+// CanTransition сообщает, может ли заказ перейти из статуса from в to.
+// Сохранение того же статуса допустимо для любого известного статуса
+func CanTransition(from, to string) bool {
+	next, ok := transitions[from]
+	if !ok {
+		return false
+	}
+	if from == to {
+		return true
+	}
+	for _, s := range next {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal сообщает, что статус окончательный и заказ больше не проверяется
+func IsFinal(status string) bool {
+	next, ok := transitions[status]
+	return ok && len(next) == 0
+}
+
+// ActiveStatuses возвращает статусы, заказы в которых ещё проверяются в системе начислений
+func ActiveStatuses() []string {
+	statuses := make([]string, 0, len(statusOrder))
+	for _, s := range statusOrder {
+		if !IsFinal(s) {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses
+}
+
+// SetStatus переводит заказ в статус status, если переход допустим
+func (o *Order) SetStatus(status string) error {
+	if !CanTransition(o.Status, status) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, o.Status, status)
+	}
+	o.Status = status
+	return nil
+}
@@ -0,0 +1,52 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{from: StatusNew, to: StatusProcessing, want: true},
+		{from: StatusNew, to: StatusProcessed, want: true},
+		{from: StatusRegistered, to: StatusInvalid, want: true},
+		{from: StatusProcessing, to: StatusProcessed, want: true},
+		{from: StatusProcessing, to: StatusProcessing, want: true},
+		{from: StatusProcessed, to: StatusProcessed, want: true},
+		{from: StatusProcessing, to: StatusNew, want: false},
+		{from: StatusProcessing, to: StatusRegistered, want: false},
+		{from: StatusProcessed, to: StatusProcessing, want: false},
+		{from: StatusProcessed, to: StatusInvalid, want: false},
+		{from: StatusInvalid, to: StatusProcessed, want: false},
+		{from: StatusNew, to: "LOST", want: false},
+		{from: "LOST", to: "LOST", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.want, CanTransition(tt.from, tt.to))
+		})
+	}
+}
+
+func TestActiveStatuses(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{StatusNew, StatusRegistered, StatusProcessing}, ActiveStatuses())
+	assert.True(IsFinal(StatusProcessed))
+	assert.True(IsFinal(StatusInvalid))
+	assert.False(IsFinal(StatusNew))
+	assert.False(IsFinal("LOST"))
+}
+
+func TestOrder_SetStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	o := Order{Status: StatusNew}
+	assert.NoError(o.SetStatus(StatusProcessing))
+	assert.NoError(o.SetStatus(StatusProcessed))
+	assert.ErrorIs(o.SetStatus(StatusProcessing), ErrIllegalTransition)
+	assert.Equal(StatusProcessed, o.Status)
+}
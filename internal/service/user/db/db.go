@@ -29,6 +29,9 @@ func (r *repository) Create(ctx context.Context, usr *user.User) error {
 		Scan(&usr.ID)
 	if err != nil {
 		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == postgres.UniqueViolation {
+			return user.ErrLoginTaken
+		}
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s",
@@ -47,7 +50,7 @@ func (r *repository) FindByLogin(ctx context.Context, login string) (user.User,
 	FROM 
 		public.users 
 	WHERE
-		login = $1
+		lower(btrim(login)) = lower(btrim($1))
 	`
 
 	var usr user.User
@@ -2,25 +2,43 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/nickzhog/gophermart/internal/repositories"
+	"github.com/nickzhog/gophermart/internal/service/loginattempt"
 	"github.com/nickzhog/gophermart/internal/service/user"
+	"github.com/nickzhog/gophermart/internal/web/response"
 	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/pkg/apperr"
 	"github.com/nickzhog/gophermart/pkg/logging"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type handler struct {
-	logger *logging.Logger
+	logger      *logging.Logger
+	cookie      session.CookieOptions
+	policy      user.PasswordPolicy
+	loginPolicy loginattempt.Policy
 	repositories.Repositories
 }
 
-func NewHandler(logger *logging.Logger, reps repositories.Repositories) *handler {
+type Options struct {
+	Cookie         session.CookieOptions
+	PasswordPolicy user.PasswordPolicy
+	LoginPolicy    loginattempt.Policy
+}
+
+func NewHandler(logger *logging.Logger, reps repositories.Repositories, opts Options) *handler {
 	return &handler{
 		logger:       logger,
+		cookie:       opts.Cookie,
+		policy:       opts.PasswordPolicy,
+		loginPolicy:  opts.LoginPolicy,
 		Repositories: reps,
 	}
 }
@@ -32,102 +50,194 @@ func (h *handler) GetRouteGroup() func(r chi.Router) {
 	}
 }
 
-func (h *handler) writeError(w http.ResponseWriter, err string, code int) {
-	h.logger.Tracef("handler return error: %s, code: %v", err, code)
-	http.Error(w, err, code)
-}
-
-func (h *handler) writeAnswer(w http.ResponseWriter, ans string, code int) {
-	h.logger.Tracef("answer: %s, code: %v", ans, code)
-
-	w.WriteHeader(code)
-	w.Write([]byte(ans))
+// GetPrivateRouteGroup маршруты, требующие действующей сессии
+func (h *handler) GetPrivateRouteGroup() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Post("/password", h.changePasswordHandler)
+	}
 }
 
 // регистрация пользователя
 func (h *handler) registerHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusBadRequest)
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
 		return
 	}
 	authData, err := user.ParseAuthRequest(body)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusBadRequest)
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
 		return
 	}
-	usr, err := user.NewUser(authData.Login, authData.Password)
+	usr, err := user.NewUser(authData.Login, authData.Password, h.policy)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	_, err = h.User.FindByLogin(r.Context(), authData.Login)
-	if !errors.Is(err, user.ErrNoRows) {
-		if err == nil {
-			h.writeError(w, "login already used", http.StatusConflict)
-			return
-		}
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
 		return
 	}
 
-	err = h.User.Create(r.Context(), &usr)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+	// занятость логина проверяет уникальный индекс, отдельный поиск допускал бы гонку,
+	// ErrLoginTaken даёт 409
+	if err = h.User.Create(r.Context(), &usr); err != nil {
+		response.Error(h.logger, w, r, err)
 		return
 	}
 
 	sID, err := session.GetSessionFromCookie(r)
 	if err == nil {
 		if err = h.Session.Disable(r.Context(), sID); err != nil {
-			h.logger.Errorf("cant disable old sessions: %s", err.Error())
-			h.writeError(w, "cant disable old sessions", http.StatusInternalServerError)
+			response.Error(h.logger, w, r, fmt.Errorf("cant disable old sessions: %w", err))
 			return
 		}
 	}
-	s, err := h.Session.Create(r.Context(), usr.ID)
+	s, err := h.Session.Create(r.Context(), usr.ID, session.ClientFromRequest(r))
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, err)
 		return
 	}
-	session.PutSessionIDInCookie(w, s.ID)
-	h.writeAnswer(w, "regiteration complete", http.StatusOK)
+	session.PutSessionIDInCookie(w, s.ID, h.cookie)
+	response.Text(h.logger, w, http.StatusOK, "regiteration complete")
 }
 
 // аутентификация пользователя
 func (h *handler) loginHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusBadRequest)
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
 		return
 	}
 	authData, err := user.ParseAuthRequest(body)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusBadRequest)
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
 		return
 	}
-	usr, err := h.User.FindByLogin(r.Context(), authData.Login)
+
+	// попытка учитывается как неудача до проверки пароля и отменяется при успешном входе
+	client := session.ClientFromRequest(r)
+	attempts, lockedFor, err := h.reserveLogin(r, authData.Login, client.IP)
 	if err != nil {
-		h.writeError(w, "user not found", http.StatusUnauthorized)
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	if lockedFor > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+		response.Error(h.logger, w, r, loginattempt.ErrLocked)
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(usr.PasswordHash), []byte(authData.Password))
-	if err != nil {
-		h.writeError(w, "wrong password", http.StatusUnauthorized)
+	// несуществующий логин и неверный пароль неотличимы ни по ответу, ни по времени
+	usr, err := h.User.FindByLogin(r.Context(), authData.Login)
+	if err != nil && !errors.Is(err, user.ErrNoRows) {
+		h.releaseLogin(r, attempts)
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	if !usr.CheckPassword(authData.Password) {
+		for _, a := range attempts {
+			if a.Locks(h.loginPolicy) {
+				h.logger.Warnf("%s locked for %s after %d failed logins",
+					a.Key, h.loginPolicy.Lockout(a.Failures, a.MaxFailures), a.Failures)
+			}
+		}
+		response.Error(h.logger, w, r, user.ErrWrongCredentials)
 		return
 	}
+	if err = h.LoginAttempt.Reset(r.Context(), attempts[0].Key); err != nil {
+		h.logger.Errorf("cant reset failed logins: %s", err.Error())
+	}
+	h.releaseLogin(r, attempts[1:])
 
 	sID, err := session.GetSessionFromCookie(r)
 	if err == nil {
-		h.Session.Disable(r.Context(), sID)
+		if err = h.Session.Disable(r.Context(), sID); err != nil {
+			response.Error(h.logger, w, r, fmt.Errorf("cant disable old sessions: %w", err))
+			return
+		}
+	}
+	s, err := h.Session.Create(r.Context(), usr.ID, session.ClientFromRequest(r))
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	session.PutSessionIDInCookie(w, s.ID, h.cookie)
+
+	response.Text(h.logger, w, http.StatusOK, "authentication complete")
+}
+
+// reserveLogin учитывает попытку входа по логину и по адресу клиента. Если один из ключей
+// заблокирован, уже учтённые попытки отменяются и возвращается время блокировки
+func (h *handler) reserveLogin(r *http.Request, login, ip string) ([]loginattempt.Attempt, time.Duration, error) {
+	attempts := make([]loginattempt.Attempt, 0, 2)
+	for _, k := range []struct {
+		key         string
+		maxFailures int
+	}{
+		{loginattempt.LoginKey(login), h.loginPolicy.MaxLoginFailures},
+		{loginattempt.IPKey(ip), h.loginPolicy.MaxIPFailures},
+	} {
+		a, err := h.LoginAttempt.Reserve(r.Context(), k.key, k.maxFailures, h.loginPolicy)
+		if err != nil || a.LockedFor > 0 {
+			h.releaseLogin(r, attempts)
+			return nil, a.LockedFor, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, 0, nil
+}
+
+// releaseLogin отменяет учёт попыток, которые не оказались неудачными
+func (h *handler) releaseLogin(r *http.Request, attempts []loginattempt.Attempt) {
+	for _, a := range attempts {
+		if err := h.LoginAttempt.Release(r.Context(), a, h.loginPolicy); err != nil {
+			h.logger.Errorf("cant release login attempt %s: %s", a.Key, err.Error())
+		}
+	}
+}
+
+// смена пароля, остальные сессии пользователя завершаются
+func (h *handler) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
+		return
+	}
+	req, err := user.ParseChangePasswordRequest(body)
+	if err != nil {
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
+		return
+	}
+
+	usrID := user.GetUserIDFromRequest(r)
+	usr, err := h.User.FindByID(r.Context(), usrID)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	if !usr.CheckPassword(req.CurrentPassword) {
+		response.Error(h.logger, w, r, user.ErrWrongPassword)
+		return
 	}
-	s, err := h.Session.Create(r.Context(), usr.ID)
+	if req.NewPassword == req.CurrentPassword {
+		response.Error(h.logger, w, r, apperr.BadRequest(user.ErrSamePassword))
+		return
+	}
+	if err = usr.SetPassword(req.NewPassword, h.policy); err != nil {
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
+		return
+	}
+
+	if err = h.User.Update(r.Context(), &usr); err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	sID := session.GetSessionIDFromRequest(r)
+	// без отзыва остальных сессий смена пароля не считается успешной
+	n, err := h.Session.DisableForUser(r.Context(), usrID, sID)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, fmt.Errorf("password changed, but cant disable other sessions: %w", err))
 		return
 	}
-	session.PutSessionIDInCookie(w, s.ID)
+	h.logger.Tracef("password of user %s changed, %d other sessions closed", usrID, n)
 
-	h.writeAnswer(w, "authentication complete", http.StatusOK)
+	response.Text(h.logger, w, http.StatusOK, "password changed")
 }
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/nickzhog/gophermart/internal/service/loginattempt"
+	mock_loginattempt "github.com/nickzhog/gophermart/internal/service/loginattempt/mocks"
 	"github.com/nickzhog/gophermart/internal/service/user"
 	mock_user "github.com/nickzhog/gophermart/internal/service/user/mocks"
 	"github.com/nickzhog/gophermart/internal/web/session"
@@ -24,12 +26,25 @@ const (
 	validUsrPassword     = "Password1234"
 	validUsrPasswordHash = "$2a$10$BlVn15UubSt8R7/w99TMceHREqd6PEwk1d42zrnKJQ6fs5XUp3Wqa"
 
-	validSessionID = "ValidSessionID"
+	validSessionID  = "ValidSessionID"
+	brokenSessionID = "BrokenSessionID"
+
+	lockedUsrLogin = "LockedLogin"
+	takenUsrLogin  = "takenlogin"
 )
 
+var loginPolicy = loginattempt.Policy{
+	MaxLoginFailures: 3,
+	MaxIPFailures:    10,
+	Window:           time.Minute,
+	LockoutBase:      time.Minute,
+	LockoutMax:       time.Hour,
+}
+
 func prepareLoginHandler(ctrl *gomock.Controller) *handler {
 	h := &handler{
-		logger: logging.GetLogger(),
+		logger:      logging.GetLogger(),
+		loginPolicy: loginPolicy,
 	}
 
 	usrRep := mock_user.NewMockRepository(ctrl)
@@ -43,8 +58,8 @@ func prepareLoginHandler(ctrl *gomock.Controller) *handler {
 	h.Repositories.User = usrRep
 
 	sessionRep := mock_session.NewMockRepository(ctrl)
-	sessionRep.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, usrID string) (session.Session, error) {
+	sessionRep.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID string, c session.Client) (session.Session, error) {
 			if usrID == validUsrID {
 				return session.Session{
 					ID:       validSessionID,
@@ -55,16 +70,63 @@ func prepareLoginHandler(ctrl *gomock.Controller) *handler {
 			}
 			return session.Session{}, errors.New("not found")
 		})
+	sessionRep.EXPECT().Disable(gomock.Any(), validSessionID).AnyTimes().Return(nil)
+	sessionRep.EXPECT().Disable(gomock.Any(), brokenSessionID).AnyTimes().Return(errors.New("db is down"))
 	h.Repositories.Session = sessionRep
 
+	loginAttemptRep := newLoginAttempts(ctrl)
+	loginAttemptRep.locked[loginattempt.LoginKey(lockedUsrLogin)] = time.Second * 90
+	loginAttemptRep.EXPECT().Reset(gomock.Any(), loginattempt.LoginKey(validUsrLogin)).AnyTimes().Return(nil)
+	h.Repositories.LoginAttempt = loginAttemptRep
+
 	return h
 }
 
+// loginAttempts учитывает попытки входа в памяти так же, как репозиторий:
+// заблокированный ключ не учитывает попытку, попытка на пределе блокирует ключ
+type loginAttempts struct {
+	*mock_loginattempt.MockRepository
+	failures map[string]int
+	locked   map[string]time.Duration
+	released []loginattempt.Attempt
+}
+
+func newLoginAttempts(ctrl *gomock.Controller) *loginAttempts {
+	la := &loginAttempts{
+		MockRepository: mock_loginattempt.NewMockRepository(ctrl),
+		failures:       make(map[string]int),
+		locked:         make(map[string]time.Duration),
+	}
+	la.EXPECT().Reserve(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, key string, maxFailures int, p loginattempt.Policy) (loginattempt.Attempt, error) {
+			a := loginattempt.Attempt{Key: key, MaxFailures: maxFailures}
+			if d := la.locked[key]; d > 0 {
+				a.LockedFor = d
+				return a, nil
+			}
+			la.failures[key]++
+			a.Failures = la.failures[key]
+			la.locked[key] = p.Lockout(a.Failures, maxFailures)
+			return a, nil
+		})
+	la.EXPECT().Release(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, a loginattempt.Attempt, p loginattempt.Policy) error {
+			la.released = append(la.released, a)
+			la.failures[a.Key]--
+			if a.Locks(p) {
+				la.locked[a.Key] = 0
+			}
+			return nil
+		})
+	return la
+}
+
 func TestHandlerData_loginHandler(t *testing.T) {
 
 	tests := []struct {
 		name        string
 		requestBody []byte
+		oldSession  string
 		wantStatus  int
 	}{
 		{
@@ -87,6 +149,23 @@ func TestHandlerData_loginHandler(t *testing.T) {
 			requestBody: []byte(`{"login":"wrong_login","password":"Password1234"}`),
 			wantStatus:  http.StatusUnauthorized,
 		},
+		{
+			name:        "locked login",
+			requestBody: []byte(`{"login":"LockedLogin","password":"Password1234"}`),
+			wantStatus:  http.StatusTooManyRequests,
+		},
+		{
+			name:        "old session disabled",
+			requestBody: []byte(`{"login":"ValidLogin","password":"Password1234"}`),
+			oldSession:  validSessionID,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "old session not disabled",
+			requestBody: []byte(`{"login":"ValidLogin","password":"Password1234"}`),
+			oldSession:  brokenSessionID,
+			wantStatus:  http.StatusInternalServerError,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -98,6 +177,9 @@ func TestHandlerData_loginHandler(t *testing.T) {
 			h := prepareLoginHandler(ctrl)
 
 			request := httptest.NewRequest(http.MethodPost, "/api/user/login", bytes.NewBuffer(tt.requestBody))
+			if tt.oldSession != "" {
+				request.AddCookie(&http.Cookie{Name: session.CookieKey, Value: tt.oldSession})
+			}
 			w := httptest.NewRecorder()
 			handler := http.HandlerFunc(h.loginHandler)
 			handler.ServeHTTP(w, request)
@@ -112,6 +194,7 @@ func TestHandlerData_loginHandler(t *testing.T) {
 func prepareRegisterHandler(ctrl *gomock.Controller) *handler {
 	h := &handler{
 		logger: logging.GetLogger(),
+		policy: user.PasswordPolicy{MinLength: 8, MinClasses: 2},
 	}
 
 	usrRep := mock_user.NewMockRepository(ctrl)
@@ -125,14 +208,17 @@ func prepareRegisterHandler(ctrl *gomock.Controller) *handler {
 
 	usrRep.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().
 		DoAndReturn(func(ctx context.Context, usr *user.User) error {
+			if usr.Login == takenUsrLogin {
+				return user.ErrLoginTaken
+			}
 			usr.ID = validUsrID
 			return nil
 		})
 	h.Repositories.User = usrRep
 
 	sessionRep := mock_session.NewMockRepository(ctrl)
-	sessionRep.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, usrID string) (session.Session, error) {
+	sessionRep.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID string, c session.Client) (session.Session, error) {
 			if usrID == validUsrID {
 				return session.Session{
 					ID:       validSessionID,
@@ -174,6 +260,21 @@ func Test_handler_registerHandler(t *testing.T) {
 			requestBody: []byte(`{"login":"","password":"Password1234"}`),
 			wantStatus:  http.StatusBadRequest,
 		},
+		{
+			name:        "login taken in other case",
+			requestBody: []byte(`{"login":" TakenLogin ","password":"Password1234"}`),
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "login with spaces inside",
+			requestBody: []byte(`{"login":"valid login","password":"Password1234"}`),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "weak password",
+			requestBody: []byte(`{"login":"ValidLogin","password":"password"}`),
+			wantStatus:  http.StatusBadRequest,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -195,3 +296,159 @@ func Test_handler_registerHandler(t *testing.T) {
 		})
 	}
 }
+
+func prepareChangePasswordHandler(ctrl *gomock.Controller) *handler {
+	h := &handler{
+		logger: logging.GetLogger(),
+		policy: user.PasswordPolicy{MinLength: 8, MinClasses: 2, CheckCommon: true},
+	}
+
+	usrRep := mock_user.NewMockRepository(ctrl)
+	usrRep.EXPECT().FindByID(gomock.Any(), gomock.Any()).AnyTimes().
+		Return(user.User{ID: validUsrID, Login: validUsrLogin, PasswordHash: validUsrPasswordHash}, nil)
+	usrRep.EXPECT().Update(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	h.Repositories.User = usrRep
+
+	sessionRep := mock_session.NewMockRepository(ctrl)
+	sessionRep.EXPECT().DisableForUser(gomock.Any(), validUsrID, validSessionID).AnyTimes().Return(int64(1), nil)
+	sessionRep.EXPECT().DisableForUser(gomock.Any(), validUsrID, unsupportedSessionID).AnyTimes().
+		Return(int64(0), session.ErrNotSupported)
+	h.Repositories.Session = sessionRep
+
+	return h
+}
+
+const unsupportedSessionID = "unsupported"
+
+func Test_handler_changePasswordHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		requestBody []byte
+		sessionID   string
+		wantStatus  int
+	}{
+		{
+			name:        "positive case",
+			requestBody: []byte(`{"current_password":"Password1234","new_password":"Correct-Horse-7"}`),
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "wrong json",
+			requestBody: []byte(`{"c`),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "wrong current password",
+			requestBody: []byte(`{"current_password":"wrong_password","new_password":"Correct-Horse-7"}`),
+			wantStatus:  http.StatusForbidden,
+		},
+		{
+			name:        "same password",
+			requestBody: []byte(`{"current_password":"Password1234","new_password":"Password1234"}`),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "common password",
+			requestBody: []byte(`{"current_password":"Password1234","new_password":"Qwerty123!"}`),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "other sessions not revoked",
+			requestBody: []byte(`{"current_password":"Password1234","new_password":"Correct-Horse-7"}`),
+			sessionID:   unsupportedSessionID,
+			wantStatus:  http.StatusNotImplemented,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			h := prepareChangePasswordHandler(ctrl)
+
+			sID := tt.sessionID
+			if sID == "" {
+				sID = validSessionID
+			}
+			request := httptest.NewRequest(http.MethodPost, "/api/user/password", bytes.NewBuffer(tt.requestBody))
+			request = session.PutSessionDataInRequest(request, sID, validUsrID)
+			w := httptest.NewRecorder()
+			handler := http.HandlerFunc(h.changePasswordHandler)
+			handler.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func Test_handler_loginHandler_lockout(t *testing.T) {
+	assert := assert.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := prepareLoginHandler(ctrl)
+
+	// после MaxLoginFailures неудач логин блокируется, и следующие попытки отклоняются
+	// без проверки пароля
+	loginAttemptRep := newLoginAttempts(ctrl)
+	h.Repositories.LoginAttempt = loginAttemptRep
+
+	var bodies []string
+	for i := 0; i < loginPolicy.MaxLoginFailures; i++ {
+		request := httptest.NewRequest(http.MethodPost, "/api/user/login",
+			bytes.NewBufferString(`{"login":"ValidLogin","password":"wrong_password"}`))
+		w := httptest.NewRecorder()
+		http.HandlerFunc(h.loginHandler).ServeHTTP(w, request)
+		res := w.Result()
+		res.Body.Close()
+
+		assert.Equal(http.StatusUnauthorized, res.StatusCode)
+		bodies = append(bodies, w.Body.String())
+	}
+	assert.Equal(loginPolicy.LockoutBase, loginAttemptRep.locked[loginattempt.LoginKey(validUsrLogin)])
+
+	// верный пароль тоже отклоняется, а учтённая попытка по адресу отменяется
+	request := httptest.NewRequest(http.MethodPost, "/api/user/login",
+		bytes.NewBufferString(`{"login":"ValidLogin","password":"Password1234"}`))
+	w := httptest.NewRecorder()
+	http.HandlerFunc(h.loginHandler).ServeHTTP(w, request)
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.Equal("60", w.Header().Get("Retry-After"))
+	assert.Equal(loginPolicy.MaxLoginFailures, loginAttemptRep.failures[loginattempt.IPKey("192.0.2.1")])
+	assert.Empty(loginAttemptRep.released)
+
+	request = httptest.NewRequest(http.MethodPost, "/api/user/login",
+		bytes.NewBufferString(`{"login":"wrong_login","password":"wrong_password"}`))
+	w = httptest.NewRecorder()
+	http.HandlerFunc(h.loginHandler).ServeHTTP(w, request)
+	assert.Equal(bodies[0], w.Body.String(), "unknown login must look like wrong password")
+}
+
+func Test_handler_loginHandler_releasesAttempt(t *testing.T) {
+	assert := assert.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := prepareLoginHandler(ctrl)
+	loginAttemptRep := newLoginAttempts(ctrl)
+	loginAttemptRep.EXPECT().Reset(gomock.Any(), loginattempt.LoginKey(validUsrLogin)).Times(1).Return(nil)
+	h.Repositories.LoginAttempt = loginAttemptRep
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/login",
+		bytes.NewBufferString(`{"login":"ValidLogin","password":"Password1234"}`))
+	w := httptest.NewRecorder()
+	http.HandlerFunc(h.loginHandler).ServeHTTP(w, request)
+
+	// успешный вход не считается неудачей по адресу клиента
+	assert.Equal(http.StatusOK, w.Code)
+	if assert.Len(loginAttemptRep.released, 1) {
+		assert.Equal(loginattempt.IPKey("192.0.2.1"), loginAttemptRep.released[0].Key)
+	}
+	assert.Zero(loginAttemptRep.failures[loginattempt.IPKey("192.0.2.1")])
+}
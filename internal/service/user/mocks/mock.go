@@ -91,4 +91,4 @@ func (m *MockRepository) Update(ctx context.Context, usr *user.User) error {
 func (mr *MockRepositoryMockRecorder) Update(ctx, usr interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), ctx, usr)
-}
\ No newline at end of file
+}
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nickzhog/gophermart/pkg/apperr"
+)
+
+// MaxPasswordLen bcrypt учитывает только первые 72 байта пароля
+const MaxPasswordLen = 72
+
+var ErrWeakPassword = apperr.New(apperr.ErrValidation, "weak password")
+
+//go:embed common_passwords.txt
+var commonPasswordsData []byte
+
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+)
+
+// PasswordPolicy требования к паролю, нулевые поля не ограничивают
+type PasswordPolicy struct {
+	MinLength int
+	// MinClasses минимальное число классов символов: строчные, заглавные, цифры, прочие
+	MinClasses  int
+	CheckCommon bool
+}
+
+// Validate проверяет пароль, ошибки оборачивают ErrWeakPassword
+func (p PasswordPolicy) Validate(login, password string) error {
+	if len(password) < 1 {
+		return fmt.Errorf("%w: password is empty", ErrWeakPassword)
+	}
+	if len(password) > MaxPasswordLen {
+		return fmt.Errorf("%w: password is longer than %d bytes", ErrWeakPassword, MaxPasswordLen)
+	}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: password is shorter than %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		return fmt.Errorf("%w: password must contain at least %d of: lowercase, uppercase, digits, other characters",
+			ErrWeakPassword, p.MinClasses)
+	}
+	if p.CheckCommon {
+		if strings.EqualFold(password, login) {
+			return fmt.Errorf("%w: password equals login", ErrWeakPassword)
+		}
+		if isCommonPassword(password) {
+			return fmt.Errorf("%w: password is too common", ErrWeakPassword)
+		}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+func isCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		s := bufio.NewScanner(bytes.NewReader(commonPasswordsData))
+		for s.Scan() {
+			if line := strings.TrimSpace(s.Text()); line != "" {
+				commonPasswords[strings.ToLower(line)] = struct{}{}
+			}
+		}
+	})
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
@@ -0,0 +1,78 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MinClasses: 3, CheckCommon: true}
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		wantErr  bool
+	}{
+		{
+			name:     "positive case",
+			login:    "Login",
+			password: "Correct-Horse-7",
+		},
+		{
+			name:     "non ascii letters",
+			login:    "Login",
+			password: "Пароль-Надёжный-7",
+		},
+		{
+			name:     "empty",
+			login:    "Login",
+			password: "",
+			wantErr:  true,
+		},
+		{
+			name:     "too short",
+			login:    "Login",
+			password: "Ab-1",
+			wantErr:  true,
+		},
+		{
+			name:     "too long for bcrypt",
+			login:    "Login",
+			password: "Aa-1" + strings.Repeat("x", MaxPasswordLen),
+			wantErr:  true,
+		},
+		{
+			name:     "not enough character classes",
+			login:    "Login",
+			password: "onlylowercase",
+			wantErr:  true,
+		},
+		{
+			name:     "common password in other case",
+			login:    "Login",
+			password: "QWERTY123!",
+			wantErr:  true,
+		},
+		{
+			name:     "equals login",
+			login:    "Strong-Login-1",
+			password: "strong-login-1",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.login, tt.password)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrWeakPassword)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_zero(t *testing.T) {
+	assert.NoError(t, PasswordPolicy{}.Validate("Login", "1"))
+}
@@ -3,7 +3,9 @@ package user
 import "context"
 
 type Repository interface {
+	// Create возвращает ErrLoginTaken, если логин уже занят
 	Create(ctx context.Context, usr *User) error
+	// FindByLogin ищет пользователя без учёта регистра и пробелов по краям логина
 	FindByLogin(ctx context.Context, login string) (User, error)
 	FindByID(ctx context.Context, id string) (User, error)
 	Update(ctx context.Context, usr *User) error
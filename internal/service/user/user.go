@@ -1,13 +1,15 @@
 package user
 
 import (
-	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/nickzhog/gophermart/internal/service/order"
-	"github.com/nickzhog/gophermart/internal/service/withdrawal"
+	"github.com/nickzhog/gophermart/pkg/apperr"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,49 +23,93 @@ type UserID string
 
 const ContextKey UserID = "user"
 
-var ErrNoRows = errors.New("user not found")
+const (
+	MinLoginLen = 3
+	MaxLoginLen = 64
+)
+
+var (
+	ErrNoRows           = apperr.New(apperr.ErrNotFound, "user not found")
+	ErrLoginTaken       = apperr.New(apperr.ErrConflict, "login already used")
+	ErrInvalidLogin     = apperr.New(apperr.ErrValidation, "invalid login")
+	ErrWrongCredentials = apperr.New(apperr.ErrUnauthorized, "wrong login or password")
+	ErrWrongPassword    = apperr.New(apperr.ErrForbidden, "wrong current password")
+	ErrSamePassword     = apperr.New(apperr.ErrValidation, "new password equals current")
+	ErrEmptyCredentials = apperr.New(apperr.ErrValidation, "login or password is empty")
+)
+
+// NormalizeLogin приводит логин к виду, в котором логины сравниваются и хранятся
+func NormalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// ValidateLogin проверяет нормализованный логин: длина в символах
+// от MinLoginLen до MaxLoginLen, без пробелов и управляющих символов
+func ValidateLogin(login string) error {
+	n := utf8.RuneCountInString(login)
+	if n < MinLoginLen || n > MaxLoginLen {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidLogin, MinLoginLen, MaxLoginLen)
+	}
+	for _, r := range login {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return fmt.Errorf("%w: whitespace and control characters are not allowed", ErrInvalidLogin)
+		}
+	}
+	return nil
+}
 
-func NewUser(login, password string) (User, error) {
+func NewUser(login, password string, policy PasswordPolicy) (User, error) {
+	login = NormalizeLogin(login)
 	if len(login) < 1 || len(password) < 1 {
-		return User{}, errors.New("login or password is empty")
+		return User{}, ErrEmptyCredentials
+	}
+	if err := ValidateLogin(login); err != nil {
+		return User{}, err
 	}
 
-	phash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+	usr := User{Login: login}
+	if err := usr.SetPassword(password, policy); err != nil {
 		return User{}, err
 	}
 
-	return User{Login: login, PasswordHash: string(phash)}, nil
+	return usr, nil
 }
 
-func (u *User) CalculateWithdrawn(
-	ctx context.Context,
-	withdrawalRep withdrawal.Repository,
-) (float64, error) {
-	withdrawals, err := withdrawalRep.FindForUser(ctx, u.ID)
-	if err != nil && err != withdrawal.ErrNoRows {
-		return 0, err
+// SetPassword проверяет пароль по политике и сохраняет его хеш
+func (u *User) SetPassword(password string, policy PasswordPolicy) error {
+	if err := policy.Validate(u.Login, password); err != nil {
+		return err
 	}
-	withdrawn := withdrawal.SumForWithdrawals(withdrawals)
-	return withdrawn, nil
-}
 
-func (u *User) CalculateBalance(
-	ctx context.Context,
-	orderRep order.Repository,
-	withdrawalRep withdrawal.Repository) (float64, error) {
-
-	orders, err := orderRep.FindForUser(ctx, u.ID)
-	if err != nil && err != order.ErrNoRows {
-		return 0, err
-	}
-	withdrawn, err := u.CalculateWithdrawn(ctx, withdrawalRep)
+	phash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	u.PasswordHash = string(phash)
+
+	return nil
+}
+
+// CheckPassword сверяет пароль с сохранённым хешем. Для пользователя без хеша
+// сравнение идёт с фиктивным хешем, чтобы время ответа не выдавало несуществующий логин
+func (u *User) CheckPassword(password string) bool {
+	if u.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return false
 	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
 
-	balance := order.AccrualSumForProcessedOrders(orders) - withdrawn
-	return balance, nil
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
 }
 
 func GetUserIDFromRequest(r *http.Request) string {
@@ -87,3 +133,20 @@ func ParseAuthRequest(data []byte) (AuthRequest, error) {
 	}
 	return authData, nil
 }
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	NewPassword     string `json:"new_password,omitempty"`
+}
+
+func ParseChangePasswordRequest(data []byte) (ChangePasswordRequest, error) {
+	var req ChangePasswordRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return ChangePasswordRequest{}, err
+	}
+	if len(req.CurrentPassword) < 1 || len(req.NewPassword) < 1 {
+		return ChangePasswordRequest{}, fmt.Errorf("%w: current or new password is empty", ErrEmptyCredentials)
+	}
+	return req, nil
+}
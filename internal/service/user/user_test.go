@@ -1,6 +1,7 @@
 package user
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,18 +33,39 @@ func TestNewUser(t *testing.T) {
 			args:    args{login: "Login", password: ""},
 			wantErr: true,
 		},
+		{
+			name:    "short login",
+			args:    args{login: " ab ", password: "Password1234"},
+			wantErr: true,
+		},
+		{
+			name:    "too long login",
+			args:    args{login: strings.Repeat("л", MaxLoginLen+1), password: "Password1234"},
+			wantErr: true,
+		},
+		{
+			name:    "control character in login",
+			args:    args{login: "Log\tin", password: "Password1234"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			assert := assert.New(t)
-			usr, err := NewUser(tt.args.login, tt.args.password)
+			usr, err := NewUser(tt.args.login, tt.args.password, PasswordPolicy{})
 
 			assert.EqualValues(tt.wantErr, err != nil)
 			if !tt.wantErr {
 				err = bcrypt.CompareHashAndPassword(
 					[]byte(usr.PasswordHash), []byte(tt.args.password))
 				assert.NoError(err)
+				assert.Equal(NormalizeLogin(tt.args.login), usr.Login)
 			}
 		})
 	}
 }
+
+func TestNormalizeLogin(t *testing.T) {
+	assert.Equal(t, "alice", NormalizeLogin("  Alice\n"))
+	assert.Equal(t, NormalizeLogin("ПОЛЬЗОВАТЕЛЬ"), NormalizeLogin("пользователь"))
+}
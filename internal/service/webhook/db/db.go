@@ -0,0 +1,350 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/postgres"
+)
+
+type repository struct {
+	client postgres.Client
+	logger *logging.Logger
+}
+
+// Enqueue записывает событие в outbox для каждой активной подписки на него в рамках
+// переданной транзакции, поэтому событие сохраняется только вместе с изменением данных
+func Enqueue(ctx context.Context, tx pgx.Tx, m webhook.Message) error {
+	payload, err := json.Marshal(m.Data)
+	if err != nil {
+		return err
+	}
+
+	q := `
+		INSERT INTO public.webhook_outbox
+		    (subscription_id, event, payload)
+		SELECT id, $1, $2::JSONB
+		FROM public.webhook_subscriptions
+		WHERE
+			active
+			AND $1 = ANY(events)
+			AND (user_id IS NULL OR user_id = $3::UUID)
+	`
+	_, err = tx.Exec(ctx, q, m.Event, string(payload), m.UserID)
+	return err
+}
+
+func (r *repository) CreateSubscription(ctx context.Context, s *webhook.Subscription) error {
+	q := `
+		INSERT INTO public.webhook_subscriptions
+		    (user_id, url, secret, events, active)
+		VALUES
+		    ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	var usrID interface{}
+	if s.UserID != "" {
+		usrID = s.UserID
+	}
+
+	err := r.client.QueryRow(ctx, q, usrID, s.URL, s.Secret, s.Events, s.Active).
+		Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) &&
+			(pgErr.Code == postgres.ForeignKeyViolation || pgErr.Code == postgres.InvalidTextRepresentation) {
+			return webhook.ErrUnknownUser
+		}
+		r.logger.Error("err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *repository) FindSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	q := `
+		SELECT
+			id, COALESCE(user_id::TEXT, ''), url,
+			events, active, created_at
+		FROM public.webhook_subscriptions
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := make([]webhook.Subscription, 0)
+
+	for rows.Next() {
+		var s webhook.Subscription
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.URL,
+			&s.Events, &s.Active, &s.CreatedAt)
+		if err != nil {
+			r.logger.Error(err)
+			return nil, err
+		}
+
+		subs = append(subs, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+func (r *repository) DisableSubscription(ctx context.Context, id string) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+		UPDATE public.webhook_subscriptions
+		SET active = false
+		WHERE id = $1
+	`
+	tag, err := tx.Exec(ctx, q, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == postgres.InvalidTextRepresentation {
+			return webhook.ErrNoRows
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return webhook.ErrNoRows
+	}
+
+	q = `
+		UPDATE public.webhook_outbox
+		SET
+		 status = $2,
+		 last_error = 'subscription disabled'
+		WHERE subscription_id = $1 AND status = $3
+	`
+	if _, err = tx.Exec(ctx, q, id, webhook.DeliveryDead, webhook.DeliveryPending); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// deliveryColumns столбцы webhook_outbox в порядке scanDelivery
+const deliveryColumns = `
+	d.id, d.subscription_id, d.event, d.payload::TEXT, d.status,
+	d.attempts, d.next_attempt_at, d.last_error,
+	COALESCE(d.last_status_code, 0), d.created_at, d.delivered_at
+`
+
+func scanDelivery(row pgx.Row, d *webhook.Delivery, extra ...interface{}) error {
+	var payload string
+	dest := append([]interface{}{
+		&d.ID, &d.SubscriptionID, &d.Event, &payload, &d.Status,
+		&d.Attempts, &d.NextAttemptAt, &d.LastError,
+		&d.LastStatusCode, &d.CreatedAt, &d.DeliveredAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	d.Payload = json.RawMessage(payload)
+	return nil
+}
+
+func (r *repository) FindDeliveries(ctx context.Context, filter webhook.DeliveryFilter) ([]webhook.Delivery, error) {
+	q := `
+		SELECT ` + deliveryColumns + `
+		FROM public.webhook_outbox d
+		WHERE
+			($1::TEXT IS NULL OR d.status = $1::TEXT)
+			AND ($2::UUID IS NULL OR d.subscription_id = $2::UUID)
+			AND d.id > $3
+		ORDER BY d.id
+		LIMIT $4
+	`
+
+	var status, subID, limit interface{}
+	if filter.Status != "" {
+		status = filter.Status
+	}
+	if filter.SubscriptionID != "" {
+		subID = filter.SubscriptionID
+	}
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+
+	rows, err := r.client.Query(ctx, q, status, subID, filter.After, limit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == postgres.InvalidTextRepresentation {
+			return nil, fmt.Errorf("%w: subscription_id", webhook.ErrBadFilter)
+		}
+		r.logger.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]webhook.Delivery, 0)
+
+	for rows.Next() {
+		var d webhook.Delivery
+
+		if err = scanDelivery(rows, &d); err != nil {
+			r.logger.Error(err)
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == postgres.InvalidTextRepresentation {
+			return nil, fmt.Errorf("%w: subscription_id", webhook.ErrBadFilter)
+		}
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *repository) FindDelivery(ctx context.Context, id int64) (webhook.Delivery, error) {
+	q := `
+		SELECT ` + deliveryColumns + `
+		FROM public.webhook_outbox d
+		WHERE d.id = $1
+	`
+
+	var d webhook.Delivery
+	if err := scanDelivery(r.client.QueryRow(ctx, q, id), &d); err != nil {
+		if err == pgx.ErrNoRows {
+			return webhook.Delivery{}, webhook.ErrNoRows
+		}
+		return webhook.Delivery{}, err
+	}
+	return d, nil
+}
+
+func (r *repository) Retry(ctx context.Context, id int64) (webhook.Delivery, error) {
+	q := `
+		UPDATE public.webhook_outbox d
+		SET
+		 status = $2,
+		 attempts = 0,
+		 next_attempt_at = CURRENT_TIMESTAMP
+		FROM public.webhook_subscriptions s
+		WHERE d.id = $1 AND d.status = $3 AND s.id = d.subscription_id AND s.active
+		RETURNING ` + deliveryColumns
+
+	var d webhook.Delivery
+	err := scanDelivery(r.client.QueryRow(ctx, q, id, webhook.DeliveryPending, webhook.DeliveryDead), &d)
+	if err != pgx.ErrNoRows {
+		return d, err
+	}
+
+	// доставки нет, она не в статусе dead или её подписка отключена
+	d, err = r.FindDelivery(ctx, id)
+	if err != nil {
+		return webhook.Delivery{}, err
+	}
+	if d.Status == webhook.DeliveryDead {
+		return webhook.Delivery{}, webhook.ErrSubscriptionDisabled
+	}
+	return webhook.Delivery{}, webhook.ErrNotDead
+}
+
+func (r *repository) FindDue(ctx context.Context, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	q := `
+		WITH due AS (
+			SELECT d.id
+			FROM public.webhook_outbox d
+			JOIN public.webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE
+				d.status = $1
+				AND s.active
+				AND d.next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY d.next_attempt_at
+			LIMIT $2
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE public.webhook_outbox d
+		SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $3::DOUBLE PRECISION)
+		FROM due, public.webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING ` + deliveryColumns + `, s.url, s.secret
+	`
+
+	rows, err := r.client.Query(ctx, q, webhook.DeliveryPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]webhook.Delivery, 0, limit)
+
+	for rows.Next() {
+		var d webhook.Delivery
+
+		if err = scanDelivery(rows, &d, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// SaveAttempt сохраняет результат попытки, время следующей попытки и доставки считается по часам БД
+func (r *repository) SaveAttempt(ctx context.Context, d *webhook.Delivery) error {
+	q := `
+		UPDATE public.webhook_outbox
+		SET
+		 status = $1,
+		 attempts = $2,
+		 next_attempt_at = CASE
+			WHEN $1 = $7 THEN CURRENT_TIMESTAMP + make_interval(secs => $3::DOUBLE PRECISION)
+			ELSE next_attempt_at
+		 END,
+		 last_error = $4,
+		 last_status_code = NULLIF($5::INTEGER, 0),
+		 delivered_at = CASE WHEN $1 = $8 THEN CURRENT_TIMESTAMP END
+		WHERE id = $6
+		RETURNING next_attempt_at, delivered_at
+	`
+
+	return r.client.QueryRow(ctx, q,
+		d.Status, d.Attempts, d.RetryIn.Seconds(), d.LastError,
+		d.LastStatusCode, d.ID, webhook.DeliveryPending, webhook.DeliveryDelivered).
+		Scan(&d.NextAttemptAt, &d.DeliveredAt)
+}
+
+func NewRepository(client postgres.Client, logger *logging.Logger) webhook.Repository {
+
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
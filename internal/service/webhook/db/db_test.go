@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/dbtest"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	"github.com/nickzhog/gophermart/internal/service/withdrawal"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_Outbox(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, pool := dbtest.Connect(t)
+	usr := dbtest.NewUser(ctx, t, pool, "webhook-outbox")
+	other := dbtest.NewUser(ctx, t, pool, "webhook-outbox-other")
+
+	rep := NewRepository(pool, logging.GetLogger())
+
+	// подписка только на списания пользователя usr
+	sub, err := webhook.NewSubscription(webhook.SubscriptionRequest{
+		UserID: usr.ID,
+		URL:    "http://localhost/hooks",
+		Events: []string{webhook.EventWithdrawalCreated},
+	})
+	require.NoError(t, err)
+	require.NoError(t, rep.CreateSubscription(ctx, &sub))
+	defer rep.DisableSubscription(context.Background(), sub.ID)
+
+	enqueue := func(m webhook.Message) {
+		tx, err := pool.Begin(ctx)
+		require.NoError(t, err)
+		defer tx.Rollback(ctx)
+		require.NoError(t, Enqueue(ctx, tx, m))
+		require.NoError(t, tx.Commit(ctx))
+	}
+	enqueue(webhook.NewWithdrawalCreated(withdrawal.Withdrawal{ID: "1", UserID: usr.ID, Sum: 100}))
+	enqueue(webhook.NewWithdrawalCreated(withdrawal.Withdrawal{ID: "2", UserID: other.ID, Sum: 100}))
+
+	found, err := rep.FindDeliveries(ctx, webhook.DeliveryFilter{SubscriptionID: sub.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(webhook.EventWithdrawalCreated, found[0].Event)
+	assert.Equal(webhook.DeliveryPending, found[0].Status)
+	assert.JSONEq(fmt.Sprintf(`{"user_id":%q,"order":"1","sum":1,"processed_at":"0001-01-01T00:00:00Z"}`, usr.ID),
+		string(found[0].Payload))
+
+	// захват: после него доставка не выдаётся повторно до истечения аренды
+	var due *webhook.Delivery
+	require.Eventually(t, func() bool {
+		ds, err := rep.FindDue(ctx, 100, time.Minute)
+		require.NoError(t, err)
+		for i := range ds {
+			if ds[i].ID == found[0].ID {
+				due = &ds[i]
+			}
+		}
+		return due != nil
+	}, time.Second*5, time.Millisecond*100)
+	assert.Equal(sub.URL, due.URL)
+	assert.Equal(sub.Secret, due.Secret)
+
+	ds, err := rep.FindDue(ctx, 100, time.Minute)
+	require.NoError(t, err)
+	for _, d := range ds {
+		assert.NotEqual(due.ID, d.ID)
+	}
+
+	due.Fail(500, "unexpected status 500", 1, time.Second, time.Second)
+	require.NoError(t, rep.SaveAttempt(ctx, due))
+
+	dead, err := rep.FindDelivery(ctx, due.ID)
+	require.NoError(t, err)
+	assert.Equal(webhook.DeliveryDead, dead.Status)
+	assert.Equal(500, dead.LastStatusCode)
+	assert.Equal(1, dead.Attempts)
+
+	retried, err := rep.Retry(ctx, due.ID)
+	require.NoError(t, err)
+	assert.Equal(webhook.DeliveryPending, retried.Status)
+	assert.Zero(retried.Attempts)
+
+	_, err = rep.Retry(ctx, due.ID)
+	assert.ErrorIs(err, webhook.ErrNotDead)
+
+	// отключение подписки останавливает её доставки
+	require.NoError(t, rep.DisableSubscription(ctx, sub.ID))
+	dead, err = rep.FindDelivery(ctx, due.ID)
+	require.NoError(t, err)
+	assert.Equal(webhook.DeliveryDead, dead.Status)
+	_, err = rep.Retry(ctx, due.ID)
+	assert.ErrorIs(err, webhook.ErrSubscriptionDisabled)
+	assert.ErrorIs(rep.DisableSubscription(ctx, "not-a-uuid"), webhook.ErrNoRows)
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/nickzhog/gophermart/pkg/page"
+)
+
+const DefaultPageLimit = 100
+
+var ErrBadFilter = page.ErrBadFilter
+
+// DeliveryFilter ограничивает выборку доставок, нулевые поля не ограничивают.
+// Доставки возвращаются по возрастанию ID, After - ID последней доставки предыдущей страницы
+type DeliveryFilter struct {
+	Status         string
+	SubscriptionID string
+	After          int64
+	Limit          int
+}
+
+// ParseDeliveryFilter разбирает параметры запроса status, subscription_id, after и limit
+func ParseDeliveryFilter(q url.Values) (DeliveryFilter, error) {
+	f := DeliveryFilter{
+		SubscriptionID: q.Get("subscription_id"),
+	}
+
+	switch v := q.Get("status"); v {
+	case "", DeliveryPending, DeliveryDelivered, DeliveryDead:
+		f.Status = v
+	default:
+		return DeliveryFilter{}, fmt.Errorf("%w: unknown status %q", ErrBadFilter, v)
+	}
+
+	var err error
+	if v := q.Get("after"); v != "" {
+		f.After, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || f.After < 0 {
+			return DeliveryFilter{}, fmt.Errorf("%w: after", ErrBadFilter)
+		}
+	}
+
+	if f.Limit, err = page.ParseLimit(q.Get("limit"), DefaultPageLimit); err != nil {
+		return DeliveryFilter{}, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeliveryFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    DeliveryFilter
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			want:  DeliveryFilter{Limit: DefaultPageLimit},
+		},
+		{
+			name:  "all params",
+			query: "status=dead&subscription_id=abc&after=10&limit=5",
+			want:  DeliveryFilter{Status: DeliveryDead, SubscriptionID: "abc", After: 10, Limit: 5},
+		},
+		{
+			name:    "unknown status",
+			query:   "status=lost",
+			wantErr: true,
+		},
+		{
+			name:    "bad after",
+			query:   "after=-1",
+			wantErr: true,
+		},
+		{
+			name:    "limit too large",
+			query:   "limit=1001",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			q, err := url.ParseQuery(tt.query)
+			assert.NoError(err)
+			got, err := ParseDeliveryFilter(q)
+			assert.Equal(tt.wantErr, err != nil)
+			if err != nil {
+				assert.ErrorIs(err, ErrBadFilter)
+			}
+			if !tt.wantErr {
+				assert.Equal(tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/nickzhog/gophermart/internal/repositories"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	"github.com/nickzhog/gophermart/internal/web/response"
+	"github.com/nickzhog/gophermart/pkg/apperr"
+	"github.com/nickzhog/gophermart/pkg/logging"
+)
+
+type handler struct {
+	logger *logging.Logger
+	repositories.Repositories
+}
+
+func NewHandler(logger *logging.Logger, reps repositories.Repositories) *handler {
+	return &handler{
+		logger:       logger,
+		Repositories: reps,
+	}
+}
+
+// GetRouteGroup маршруты администратора, доступ к ним проверяется снаружи
+func (h *handler) GetRouteGroup() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Get("/webhooks/subscriptions", h.subscriptionsHandler)
+		r.Post("/webhooks/subscriptions", h.newSubscriptionHandler)
+		r.Delete("/webhooks/subscriptions/{id}", h.disableSubscriptionHandler)
+		r.Get("/webhooks/deliveries", h.deliveriesHandler)
+		r.Get("/webhooks/deliveries/{id}", h.deliveryHandler)
+		r.Post("/webhooks/deliveries/{id}/retry", h.retryDeliveryHandler)
+	}
+}
+
+// список подписок без секретов
+func (h *handler) subscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.Webhook.FindSubscriptions(r.Context())
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	response.JSON(h.logger, w, r, http.StatusOK, subs)
+}
+
+// создание подписки, секрет для проверки подписи возвращается только в этом ответе
+func (h *handler) newSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
+		return
+	}
+	req, err := webhook.ParseSubscriptionRequest(body)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	sub, err := webhook.NewSubscription(req)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	if err = h.Webhook.CreateSubscription(r.Context(), &sub); err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	h.logger.Tracef("new webhook subscription %s: %s %v", sub.ID, sub.URL, sub.Events)
+
+	response.JSON(h.logger, w, r, http.StatusCreated, sub)
+}
+
+// отключение подписки, её недоставленные события больше не отправляются
+func (h *handler) disableSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.Webhook.DisableSubscription(r.Context(), id); err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	response.Text(h.logger, w, http.StatusOK, "subscription disabled")
+}
+
+// список доставок по возрастанию ID с постраничной выдачей
+func (h *handler) deliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := webhook.ParseDeliveryFilter(r.URL.Query())
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	// запрашиваем на одну доставку больше, чтобы узнать, есть ли следующая страница
+	query := filter
+	query.Limit++
+	deliveries, err := h.Webhook.FindDeliveries(r.Context(), query)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+		last := deliveries[len(deliveries)-1]
+		response.SetNextPage(w, r, strconv.FormatInt(last.ID, 10))
+	}
+
+	response.JSON(h.logger, w, r, http.StatusOK, deliveries)
+}
+
+func (h *handler) deliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := deliveryID(r)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	d, err := h.Webhook.FindDelivery(r.Context(), id)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	response.JSON(h.logger, w, r, http.StatusOK, d)
+}
+
+// повторная отправка окончательно неудачной доставки
+func (h *handler) retryDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := deliveryID(r)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	d, err := h.Webhook.Retry(r.Context(), id)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	h.logger.Tracef("webhook delivery %d queued for retry", d.ID)
+
+	response.JSON(h.logger, w, r, http.StatusOK, d)
+}
+
+func deliveryID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, webhook.ErrNoRows
+	}
+	return id, nil
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	mock_webhook "github.com/nickzhog/gophermart/internal/service/webhook/mocks"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	validSubscriptionID = "c3a1c1f4-5a5e-4a53-9a7c-21f1c0bb9f11"
+	unknownUserID       = "unknown-user"
+)
+
+var deliveries = []webhook.Delivery{
+	{ID: 1, SubscriptionID: validSubscriptionID, Event: webhook.EventOrderAccrued, Status: webhook.DeliveryDelivered},
+	{ID: 2, SubscriptionID: validSubscriptionID, Event: webhook.EventWithdrawalCreated, Status: webhook.DeliveryDead},
+	{ID: 3, SubscriptionID: validSubscriptionID, Event: webhook.EventOrderAccrued, Status: webhook.DeliveryPending},
+}
+
+func prepareRouter(ctrl *gomock.Controller) chi.Router {
+	h := &handler{
+		logger: logging.GetLogger(),
+	}
+
+	webhookRep := mock_webhook.NewMockRepository(ctrl)
+	webhookRep.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, s *webhook.Subscription) error {
+			if s.UserID == unknownUserID {
+				return webhook.ErrUnknownUser
+			}
+			s.ID = validSubscriptionID
+			s.CreatedAt = time.Now()
+			return nil
+		})
+
+	webhookRep.EXPECT().DisableSubscription(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, id string) error {
+			if id != validSubscriptionID {
+				return webhook.ErrNoRows
+			}
+			return nil
+		})
+
+	webhookRep.EXPECT().FindDeliveries(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, filter webhook.DeliveryFilter) ([]webhook.Delivery, error) {
+			found := make([]webhook.Delivery, 0)
+			for _, d := range deliveries {
+				if d.ID > filter.After && (filter.Status == "" || filter.Status == d.Status) {
+					found = append(found, d)
+				}
+			}
+			if len(found) > filter.Limit {
+				found = found[:filter.Limit]
+			}
+			return found, nil
+		})
+
+	findDelivery := func(ctx context.Context, id int64) (webhook.Delivery, error) {
+		for _, d := range deliveries {
+			if d.ID == id {
+				return d, nil
+			}
+		}
+		return webhook.Delivery{}, webhook.ErrNoRows
+	}
+	webhookRep.EXPECT().FindDelivery(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(findDelivery)
+
+	webhookRep.EXPECT().Retry(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, id int64) (webhook.Delivery, error) {
+			d, err := findDelivery(ctx, id)
+			if err != nil {
+				return webhook.Delivery{}, err
+			}
+			if d.Status != webhook.DeliveryDead {
+				return webhook.Delivery{}, webhook.ErrNotDead
+			}
+			d.Status, d.Attempts = webhook.DeliveryPending, 0
+			return d, nil
+		})
+
+	h.Repositories.Webhook = webhookRep
+
+	router := chi.NewRouter()
+	router.Group(h.GetRouteGroup())
+	return router
+}
+
+func serve(router chi.Router, method, target string, body []byte) *http.Response {
+	request := httptest.NewRequest(method, target, bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	return w.Result()
+}
+
+func Test_handler_newSubscriptionHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		requestBody []byte
+		wantStatus  int
+	}{
+		{
+			name:        "positive case",
+			requestBody: []byte(`{"url":"https://crm.example.com/hooks"}`),
+			wantStatus:  http.StatusCreated,
+		},
+		{
+			name:        "wrong json",
+			requestBody: []byte(`{"url`),
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "wrong url",
+			requestBody: []byte(`{"url":"crm.example.com"}`),
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "unknown user",
+			requestBody: []byte(`{"url":"https://crm.example.com/hooks","user_id":"unknown-user"}`),
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			res := serve(prepareRouter(ctrl), http.MethodPost, "/webhooks/subscriptions", tt.requestBody)
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+			if res.StatusCode == http.StatusCreated {
+				var sub webhook.Subscription
+				assert.NoError(json.NewDecoder(res.Body).Decode(&sub))
+				assert.Equal(validSubscriptionID, sub.ID)
+				assert.NotEmpty(sub.Secret)
+			}
+		})
+	}
+}
+
+func Test_handler_disableSubscriptionHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	router := prepareRouter(ctrl)
+
+	res := serve(router, http.MethodDelete, "/webhooks/subscriptions/"+validSubscriptionID, nil)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = serve(router, http.MethodDelete, "/webhooks/subscriptions/unknown", nil)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func Test_handler_deliveriesHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int64
+		wantNext   string
+	}{
+		{
+			name:       "all deliveries",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{1, 2, 3},
+		},
+		{
+			name:       "first page",
+			query:      "?limit=2",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{1, 2},
+			wantNext:   "2",
+		},
+		{
+			name:       "last page",
+			query:      "?limit=2&after=2",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{3},
+		},
+		{
+			name:       "dead only",
+			query:      "?status=dead",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{2},
+		},
+		{
+			name:       "wrong status",
+			query:      "?status=lost",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			res := serve(prepareRouter(ctrl), http.MethodGet, "/webhooks/deliveries"+tt.query, nil)
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+			assert.Equal(tt.wantNext, res.Header.Get("X-Next-Cursor"))
+			if res.StatusCode == http.StatusOK {
+				var got []webhook.Delivery
+				assert.NoError(json.NewDecoder(res.Body).Decode(&got))
+				ids := make([]int64, 0, len(got))
+				for _, d := range got {
+					ids = append(ids, d.ID)
+				}
+				assert.Equal(tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func Test_handler_retryDeliveryHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{
+			name:       "dead delivery",
+			id:         "2",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "delivered delivery",
+			id:         "1",
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "unknown delivery",
+			id:         "42",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "wrong id",
+			id:         "abc",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			res := serve(prepareRouter(ctrl), http.MethodPost, "/webhooks/deliveries/"+tt.id+"/retry", nil)
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+			if res.StatusCode == http.StatusOK {
+				var d webhook.Delivery
+				assert.NoError(json.NewDecoder(res.Body).Decode(&d))
+				assert.Equal(webhook.DeliveryPending, d.Status)
+			}
+		})
+	}
+}
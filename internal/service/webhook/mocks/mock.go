@@ -0,0 +1,154 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: repository.go
+
+// Package mock_webhook is a generated GoMock package.
+package mock_webhook
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	webhook "github.com/nickzhog/gophermart/internal/service/webhook"
+)
+
+// MockRepository is a mock of Repository interface.
+type MockRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRepositoryMockRecorder
+}
+
+// MockRepositoryMockRecorder is the mock recorder for MockRepository.
+type MockRepositoryMockRecorder struct {
+	mock *MockRepository
+}
+
+// NewMockRepository creates a new mock instance.
+func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
+	mock := &MockRepository{ctrl: ctrl}
+	mock.recorder = &MockRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateSubscription mocks base method.
+func (m *MockRepository) CreateSubscription(ctx context.Context, s *webhook.Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription.
+func (mr *MockRepositoryMockRecorder) CreateSubscription(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockRepository)(nil).CreateSubscription), ctx, s)
+}
+
+// DisableSubscription mocks base method.
+func (m *MockRepository) DisableSubscription(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableSubscription", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableSubscription indicates an expected call of DisableSubscription.
+func (mr *MockRepositoryMockRecorder) DisableSubscription(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableSubscription", reflect.TypeOf((*MockRepository)(nil).DisableSubscription), ctx, id)
+}
+
+// FindDeliveries mocks base method.
+func (m *MockRepository) FindDeliveries(ctx context.Context, filter webhook.DeliveryFilter) ([]webhook.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDeliveries", ctx, filter)
+	ret0, _ := ret[0].([]webhook.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDeliveries indicates an expected call of FindDeliveries.
+func (mr *MockRepositoryMockRecorder) FindDeliveries(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDeliveries", reflect.TypeOf((*MockRepository)(nil).FindDeliveries), ctx, filter)
+}
+
+// FindDelivery mocks base method.
+func (m *MockRepository) FindDelivery(ctx context.Context, id int64) (webhook.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDelivery", ctx, id)
+	ret0, _ := ret[0].(webhook.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDelivery indicates an expected call of FindDelivery.
+func (mr *MockRepositoryMockRecorder) FindDelivery(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDelivery", reflect.TypeOf((*MockRepository)(nil).FindDelivery), ctx, id)
+}
+
+// FindDue mocks base method.
+func (m *MockRepository) FindDue(ctx context.Context, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDue", ctx, limit, lease)
+	ret0, _ := ret[0].([]webhook.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDue indicates an expected call of FindDue.
+func (mr *MockRepositoryMockRecorder) FindDue(ctx, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDue", reflect.TypeOf((*MockRepository)(nil).FindDue), ctx, limit, lease)
+}
+
+// FindSubscriptions mocks base method.
+func (m *MockRepository) FindSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSubscriptions", ctx)
+	ret0, _ := ret[0].([]webhook.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSubscriptions indicates an expected call of FindSubscriptions.
+func (mr *MockRepositoryMockRecorder) FindSubscriptions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSubscriptions", reflect.TypeOf((*MockRepository)(nil).FindSubscriptions), ctx)
+}
+
+// Retry mocks base method.
+func (m *MockRepository) Retry(ctx context.Context, id int64) (webhook.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Retry", ctx, id)
+	ret0, _ := ret[0].(webhook.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Retry indicates an expected call of Retry.
+func (mr *MockRepositoryMockRecorder) Retry(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retry", reflect.TypeOf((*MockRepository)(nil).Retry), ctx, id)
+}
+
+// SaveAttempt mocks base method.
+func (m *MockRepository) SaveAttempt(ctx context.Context, d *webhook.Delivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAttempt", ctx, d)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAttempt indicates an expected call of SaveAttempt.
+func (mr *MockRepositoryMockRecorder) SaveAttempt(ctx, d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAttempt", reflect.TypeOf((*MockRepository)(nil).SaveAttempt), ctx, d)
+}
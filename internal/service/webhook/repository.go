@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// CreateSubscription сохраняет подписку. Несуществующий пользователь даёт ErrUnknownUser
+	CreateSubscription(ctx context.Context, s *Subscription) error
+	// FindSubscriptions возвращает все подписки без секретов по возрастанию времени создания
+	FindSubscriptions(ctx context.Context) ([]Subscription, error)
+	// DisableSubscription отключает подписку, её недоставленные события становятся DeliveryDead
+	DisableSubscription(ctx context.Context, id string) error
+
+	// FindDeliveries возвращает доставки по возрастанию ID
+	FindDeliveries(ctx context.Context, filter DeliveryFilter) ([]Delivery, error)
+	FindDelivery(ctx context.Context, id int64) (Delivery, error)
+	// Retry возвращает окончательно неудачную доставку в очередь с обнулённым счётчиком попыток.
+	// Доставка в другом статусе даёт ErrNotDead, доставка отключённой подписки ErrSubscriptionDisabled
+	Retry(ctx context.Context, id int64) (Delivery, error)
+
+	// FindDue захватывает до limit доставок активных подписок, время попытки которых наступило,
+	// и откладывает их следующую попытку на lease, чтобы другие экземпляры их пропустили
+	FindDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	// SaveAttempt сохраняет результат попытки доставки
+	SaveAttempt(ctx context.Context, d *Delivery) error
+}
@@ -0,0 +1,286 @@
+// Package webhook описывает подписки внешних систем на события начислений и списаний
+// и доставку этих событий HTTP-запросами с подписью HMAC
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/internal/service/withdrawal"
+	"github.com/nickzhog/gophermart/pkg/apperr"
+	"github.com/nickzhog/gophermart/pkg/money"
+)
+
+// события
+const (
+	EventOrderAccrued      = "order.accrued"      // баллы за заказ начислены
+	EventWithdrawalCreated = "withdrawal.created" // баллы списаны
+)
+
+// статусы доставки
+const (
+	DeliveryPending   = "pending"   // ждёт очередной попытки
+	DeliveryDelivered = "delivered" // получатель ответил 2xx
+	DeliveryDead      = "dead"      // попытки исчерпаны или подписка отключена
+)
+
+// заголовки запроса доставки
+const (
+	HeaderEvent     = "X-Gophermart-Event"
+	HeaderDelivery  = "X-Gophermart-Delivery"
+	HeaderSignature = "X-Gophermart-Signature"
+)
+
+var (
+	ErrNoRows               = apperr.New(apperr.ErrNotFound, "webhook not found")
+	ErrInvalidURL           = apperr.New(apperr.ErrValidation, "webhook url must be absolute http or https url")
+	ErrUnknownEvent         = apperr.New(apperr.ErrValidation, "unknown webhook event")
+	ErrUnknownUser          = apperr.New(apperr.ErrValidation, "unknown user")
+	ErrNotDead              = apperr.New(apperr.ErrConflict, "only dead deliveries can be retried")
+	ErrSubscriptionDisabled = apperr.New(apperr.ErrConflict, "subscription of the delivery is disabled")
+	ErrBadSignature         = apperr.New(apperr.ErrUnauthorized, "bad webhook signature")
+	ErrEmptySubscriptions   = apperr.New(apperr.ErrValidation, "at least one event is required")
+)
+
+// Events возвращает все события, на которые можно подписаться
+func Events() []string {
+	return []string{EventOrderAccrued, EventWithdrawalCreated}
+}
+
+// Subscription подписка на события одного пользователя или, без UserID, всех пользователей
+type Subscription struct {
+	ID     string   `json:"id"`
+	UserID string   `json:"user_id,omitempty"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret показывается только при создании подписки
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SubscriptionRequest struct {
+	UserID string   `json:"user_id,omitempty"`
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+func ParseSubscriptionRequest(data []byte) (SubscriptionRequest, error) {
+	var req SubscriptionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return SubscriptionRequest{}, apperr.Validation(err)
+	}
+	return req, nil
+}
+
+// NewSubscription проверяет запрос и создаёт подписку с новым секретом.
+// Без списка событий подписка получает все события
+func NewSubscription(req SubscriptionRequest) (Subscription, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, ErrInvalidURL
+	}
+
+	events := req.Events
+	if events == nil {
+		events = Events()
+	}
+	if len(events) < 1 {
+		return Subscription{}, ErrEmptySubscriptions
+	}
+	for _, e := range events {
+		if !knownEvent(e) {
+			return Subscription{}, fmt.Errorf("%w: %q", ErrUnknownEvent, e)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return Subscription{}, err
+	}
+
+	return Subscription{
+		UserID: strings.TrimSpace(req.UserID),
+		URL:    req.URL,
+		Events: events,
+		Secret: hex.EncodeToString(secret),
+		Active: true,
+	}, nil
+}
+
+func knownEvent(e string) bool {
+	for _, known := range Events() {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Message событие для outbox: оно записывается для каждой подходящей подписки
+type Message struct {
+	Event  string
+	UserID string
+	Data   interface{}
+}
+
+type OrderAccrued struct {
+	UserID      string       `json:"user_id"`
+	Number      string       `json:"number"`
+	Accrual     money.Amount `json:"accrual"`
+	ProcessedAt time.Time    `json:"processed_at"`
+}
+
+type WithdrawalCreated struct {
+	UserID      string       `json:"user_id"`
+	Order       string       `json:"order"`
+	Sum         money.Amount `json:"sum"`
+	ProcessedAt time.Time    `json:"processed_at"`
+}
+
+func NewOrderAccrued(o order.Order, processedAt time.Time) Message {
+	return Message{
+		Event:  EventOrderAccrued,
+		UserID: o.UserID,
+		Data: OrderAccrued{
+			UserID:      o.UserID,
+			Number:      o.ID,
+			Accrual:     o.Accrual,
+			ProcessedAt: processedAt,
+		},
+	}
+}
+
+func NewWithdrawalCreated(w withdrawal.Withdrawal) Message {
+	return Message{
+		Event:  EventWithdrawalCreated,
+		UserID: w.UserID,
+		Data: WithdrawalCreated{
+			UserID:      w.UserID,
+			Order:       w.ID,
+			Sum:         w.Sum,
+			ProcessedAt: w.ProcessedAt,
+		},
+	}
+}
+
+// Delivery доставка события по одной подписке
+type Delivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// RetryIn задержка следующей попытки, сам момент попытки считает БД по своим часам
+	RetryIn time.Duration `json:"-"`
+
+	// адрес и секрет подписки для отправки
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// envelope тело запроса доставки
+type envelope struct {
+	ID        int64           `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Body возвращает тело запроса доставки
+func (d *Delivery) Body() ([]byte, error) {
+	return json.Marshal(envelope{
+		ID:        d.ID,
+		Event:     d.Event,
+		CreatedAt: d.CreatedAt,
+		Data:      d.Payload,
+	})
+}
+
+// Succeed отмечает успешную доставку, время доставки записывает БД
+func (d *Delivery) Succeed(statusCode int) {
+	d.Attempts++
+	d.Status = DeliveryDelivered
+	d.LastStatusCode = statusCode
+	d.LastError = ""
+	d.RetryIn = 0
+}
+
+// Fail отмечает неудачную попытку. Следующая попытка откладывается экспоненциально
+// от base до max, после maxAttempts попыток доставка становится окончательно неудачной
+func (d *Delivery) Fail(statusCode int, reason string, maxAttempts int, base, max time.Duration) {
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	d.LastError = reason
+	d.RetryIn = 0
+	if d.Attempts >= maxAttempts {
+		d.Status = DeliveryDead
+		return
+	}
+
+	delay := base
+	for i := 1; i < d.Attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	d.Status = DeliveryPending
+	d.RetryIn = delay
+}
+
+// Sign возвращает значение заголовка подписи: время отправки и HMAC-SHA256
+// от строки "<время>.<тело>" на секрете подписки
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + signature(secret, t, body)
+}
+
+// Verify проверяет подпись на стороне получателя. Подписи старше tolerance отклоняются
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sig = v
+		}
+	}
+	ts, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || sig == "" {
+		return ErrBadSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("%w: signature expired", ErrBadSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, t, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func signature(secret, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/internal/service/withdrawal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSubscription(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        SubscriptionRequest
+		wantEvents []string
+		wantErr    error
+	}{
+		{
+			name:       "all events by default",
+			req:        SubscriptionRequest{URL: "https://crm.example.com/hooks"},
+			wantEvents: []string{EventOrderAccrued, EventWithdrawalCreated},
+		},
+		{
+			name:       "single event for user",
+			req:        SubscriptionRequest{UserID: " user ", URL: "http://crm:8080/hooks", Events: []string{EventWithdrawalCreated}},
+			wantEvents: []string{EventWithdrawalCreated},
+		},
+		{
+			name:    "unknown event",
+			req:     SubscriptionRequest{URL: "https://crm.example.com/hooks", Events: []string{"order.created"}},
+			wantErr: ErrUnknownEvent,
+		},
+		{
+			name:    "empty events",
+			req:     SubscriptionRequest{URL: "https://crm.example.com/hooks", Events: []string{}},
+			wantErr: ErrEmptySubscriptions,
+		},
+		{
+			name:    "relative url",
+			req:     SubscriptionRequest{URL: "/hooks"},
+			wantErr: ErrInvalidURL,
+		},
+		{
+			name:    "wrong scheme",
+			req:     SubscriptionRequest{URL: "ftp://crm.example.com/hooks"},
+			wantErr: ErrInvalidURL,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, err := NewSubscription(tt.req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.wantEvents, got.Events)
+			assert.Equal(tt.req.URL, got.URL)
+			assert.Len(got.Secret, 64)
+			assert.True(got.Active)
+			if tt.req.UserID != "" {
+				assert.Equal("user", got.UserID)
+			}
+		})
+	}
+}
+
+func TestSubscription_secretsDiffer(t *testing.T) {
+	req := SubscriptionRequest{URL: "https://crm.example.com/hooks"}
+	a, err := NewSubscription(req)
+	require.NoError(t, err)
+	b, err := NewSubscription(req)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Secret, b.Secret)
+}
+
+func TestSignVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1665000000, 0)
+	body := []byte(`{"id":1}`)
+	header := Sign("secret", now, body)
+	assert.Regexp(`^t=1665000000,v1=[0-9a-f]{64}$`, header)
+
+	assert.NoError(Verify("secret", header, body, now.Add(time.Second*30), time.Minute))
+	assert.ErrorIs(Verify("other", header, body, now, time.Minute), ErrBadSignature)
+	assert.ErrorIs(Verify("secret", header, []byte(`{"id":2}`), now, time.Minute), ErrBadSignature)
+	assert.ErrorIs(Verify("secret", header, body, now.Add(time.Hour), time.Minute), ErrBadSignature)
+	assert.ErrorIs(Verify("secret", "v1=abc", body, now, time.Minute), ErrBadSignature)
+	assert.ErrorIs(Verify("secret", "", body, now, time.Minute), ErrBadSignature)
+}
+
+func TestDelivery_Fail(t *testing.T) {
+	assert := assert.New(t)
+
+	base, max := time.Second*10, time.Second*30
+	d := Delivery{Status: DeliveryPending}
+
+	d.Fail(500, "unexpected status 500", 4, base, max)
+	assert.Equal(DeliveryPending, d.Status)
+	assert.Equal(1, d.Attempts)
+	assert.Equal(500, d.LastStatusCode)
+	assert.Equal("unexpected status 500", d.LastError)
+	assert.Equal(base, d.RetryIn)
+
+	d.Fail(0, "connection refused", 4, base, max)
+	assert.Equal(base*2, d.RetryIn)
+	assert.Equal(0, d.LastStatusCode)
+
+	d.Fail(0, "connection refused", 4, base, max)
+	assert.Equal(max, d.RetryIn)
+
+	d.Fail(0, "connection refused", 4, base, max)
+	assert.Equal(DeliveryDead, d.Status)
+	assert.Zero(d.RetryIn)
+	assert.Equal(4, d.Attempts)
+}
+
+func TestDelivery_Succeed(t *testing.T) {
+	assert := assert.New(t)
+
+	d := Delivery{Status: DeliveryPending, Attempts: 1, LastError: "timeout", RetryIn: time.Minute}
+	d.Succeed(204)
+
+	assert.Equal(DeliveryDelivered, d.Status)
+	assert.Equal(2, d.Attempts)
+	assert.Equal(204, d.LastStatusCode)
+	assert.Empty(d.LastError)
+	assert.Zero(d.RetryIn)
+}
+
+func TestMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	m := NewOrderAccrued(order.Order{ID: "2377225624", UserID: "user", Accrual: 50050}, now)
+	assert.Equal(EventOrderAccrued, m.Event)
+	assert.Equal("user", m.UserID)
+	data, err := json.Marshal(m.Data)
+	assert.NoError(err)
+	assert.JSONEq(`{"user_id":"user","number":"2377225624","accrual":500.5,"processed_at":"2022-10-01T12:00:00Z"}`, string(data))
+
+	m = NewWithdrawalCreated(withdrawal.Withdrawal{ID: "2377225624", UserID: "user", Sum: 100, ProcessedAt: now})
+	assert.Equal(EventWithdrawalCreated, m.Event)
+	data, err = json.Marshal(m.Data)
+	assert.NoError(err)
+	assert.JSONEq(`{"user_id":"user","order":"2377225624","sum":1,"processed_at":"2022-10-01T12:00:00Z"}`, string(data))
+}
+
+func TestDelivery_Body(t *testing.T) {
+	d := Delivery{
+		ID:        7,
+		Event:     EventOrderAccrued,
+		Payload:   json.RawMessage(`{"number":"2377225624"}`),
+		CreatedAt: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+		URL:       "https://crm.example.com/hooks",
+		Secret:    "secret",
+	}
+	body, err := d.Body()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":7,"event":"order.accrued","created_at":"2022-10-01T12:00:00Z","data":{"number":"2377225624"}}`, string(body))
+}
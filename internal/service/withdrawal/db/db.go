@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
+	"github.com/nickzhog/gophermart/internal/service/ledger"
+	ledgerdb "github.com/nickzhog/gophermart/internal/service/ledger/db"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	webhookdb "github.com/nickzhog/gophermart/internal/service/webhook/db"
 	"github.com/nickzhog/gophermart/internal/service/withdrawal"
 	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/money"
 	"github.com/nickzhog/gophermart/pkg/postgres"
 )
 
@@ -19,46 +23,124 @@ type repository struct {
 }
 
 func (r *repository) Create(ctx context.Context, w *withdrawal.Withdrawal) error {
+	entry, err := ledger.NewWithdrawalEntry(w.UserID, w.ID, w.Sum)
+	if err != nil {
+		return err
+	}
 
-	w.Sum = fmt.Sprintf("%g", w.SumFloat)
-	q := `
-		INSERT INTO public.withdrawals 
-		    (id, user_id, sum) 
-		VALUES 
-		    ($1, $2, $3) 
-		RETURNING processed_at
-	`
-	err := r.client.QueryRow(ctx, q, w.ID, w.UserID, w.Sum).Scan(&w.ProcessedAt)
+	tx, err := r.client.Begin(ctx)
 	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = r.create(ctx, tx, w, &entry); err != nil {
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
+		switch {
+		case errors.Is(err, withdrawal.ErrInsufficientFunds):
+			return err
+		case errors.Is(err, ledger.ErrDuplicateEntry):
+			return withdrawal.ErrAlreadyExists
+		case errors.As(err, &pgErr) && pgErr.Code == postgres.UniqueViolation:
+			return withdrawal.ErrAlreadyExists
+		case errors.As(err, &pgErr) && pgErr.Code == postgres.CheckViolation:
+			return withdrawal.ErrInsufficientFunds
+		case errors.As(err, &pgErr):
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s",
 				pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error("err:", newErr.Error())
 		}
 		r.logger.Error("err:", err.Error())
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// create выполняет списание под блокировкой строки баланса пользователя,
+// поэтому параллельные списания одного пользователя выполняются последовательно
+func (r *repository) create(ctx context.Context, tx pgx.Tx, w *withdrawal.Withdrawal, entry *ledger.Entry) error {
+	q := `
+		INSERT INTO public.balances 
+		    (user_id) 
+		VALUES 
+		    ($1) 
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, q, w.UserID); err != nil {
+		return err
+	}
+
+	q = `
+		SELECT current 
+		FROM public.balances 
+		WHERE user_id = $1 
+		FOR UPDATE
+	`
+	var current money.Amount
+	if err := tx.QueryRow(ctx, q, w.UserID).Scan(&current); err != nil {
+		return err
+	}
+	if current < w.Sum {
+		return withdrawal.ErrInsufficientFunds
+	}
+
+	q = `
+		INSERT INTO public.withdrawals 
+		    (id, user_id, sum) 
+		VALUES 
+		    ($1, $2, $3) 
+		RETURNING processed_at
+	`
+	if err := tx.QueryRow(ctx, q, w.ID, w.UserID, w.Sum).Scan(&w.ProcessedAt); err != nil {
+		return err
+	}
+
+	if err := ledgerdb.Post(ctx, tx, entry); err != nil {
+		return err
 	}
-	return err
+
+	return webhookdb.Enqueue(ctx, tx, webhook.NewWithdrawalCreated(*w))
 }
 
-func (r *repository) FindForUser(ctx context.Context, usrID string) ([]withdrawal.Withdrawal, error) {
+func (r *repository) FindForUser(ctx context.Context, usrID string, filter withdrawal.Filter) ([]withdrawal.Withdrawal, error) {
 	q := `
 		SELECT 
 			id, user_id, sum, processed_at
 		FROM 
 			public.withdrawals 
 		WHERE 
-			user_id = $1;
+			user_id = $1
+			AND ($2::TIMESTAMP IS NULL OR processed_at >= $2::TIMESTAMP)
+			AND ($3::TIMESTAMP IS NULL OR processed_at < $3::TIMESTAMP)
+			AND ($4::TIMESTAMP IS NULL OR (processed_at, id) > ($4::TIMESTAMP, $5::TEXT))
+		ORDER BY processed_at, id
+		LIMIT $6
 	`
 
-	rows, err := r.client.Query(ctx, q, usrID)
+	var from, to, afterProcessedAt, afterID, limit interface{}
+	if !filter.From.IsZero() {
+		from = filter.From.UTC()
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.UTC()
+	}
+	if filter.After != nil {
+		afterProcessedAt, afterID = filter.After.At.UTC(), filter.After.ID
+	}
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+
+	rows, err := r.client.Query(ctx, q, usrID,
+		from, to, afterProcessedAt, afterID, limit)
 	if err != nil {
 		r.logger.Error(err)
 		return nil, err
 	}
+	defer rows.Close()
 
-	wdls := make([]withdrawal.Withdrawal, rows.CommandTag().RowsAffected())
+	wdls := make([]withdrawal.Withdrawal, 0)
 
 	for rows.Next() {
 		var w withdrawal.Withdrawal
@@ -70,11 +152,6 @@ func (r *repository) FindForUser(ctx context.Context, usrID string) ([]withdrawa
 			return nil, err
 		}
 
-		if w.SumFloat, err = strconv.ParseFloat(w.Sum, 64); err != nil {
-			r.logger.Error(err)
-			return nil, err
-		}
-
 		wdls = append(wdls, w)
 	}
 
@@ -106,11 +183,6 @@ func (r *repository) FindByID(ctx context.Context, id string) (withdrawal.Withdr
 		return withdrawal.Withdrawal{}, err
 	}
 
-	w.SumFloat, err = strconv.ParseFloat(w.Sum, 64)
-	if err != nil {
-		return withdrawal.Withdrawal{}, err
-	}
-
 	return w, nil
 }
 
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/dbtest"
+	"github.com/nickzhog/gophermart/internal/service/ledger"
+	ledgerdb "github.com/nickzhog/gophermart/internal/service/ledger/db"
+	"github.com/nickzhog/gophermart/internal/service/withdrawal"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_CreateConcurrent(t *testing.T) {
+	const (
+		accrual  money.Amount = 10000
+		sum      money.Amount = 1000
+		attempts              = 30
+	)
+
+	ctx, pool := dbtest.Connect(t)
+	logger := logging.GetLogger()
+	seed := time.Now().UnixNano()
+	usr := dbtest.NewUser(ctx, t, pool, "withdrawal-race")
+
+	ledgerRep := ledgerdb.NewRepository(pool, logger)
+	entry, err := ledger.NewAccrualEntry(usr.ID, fmt.Sprintf("accrual-%d", seed), accrual)
+	require.NoError(t, err)
+	require.NoError(t, ledgerRep.Post(ctx, &entry))
+
+	rep := NewRepository(pool, logger)
+
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		succeeded    int
+		insufficient int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := withdrawal.Withdrawal{
+				ID:     fmt.Sprintf("%d%02d", seed, i),
+				UserID: usr.ID,
+				Sum:    sum,
+			}
+			err := rep.Create(ctx, &w)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, withdrawal.ErrInsufficientFunds):
+				insufficient++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert := assert.New(t)
+	assert.Equal(int(accrual/sum), succeeded)
+	assert.Equal(attempts-int(accrual/sum), insufficient)
+
+	balance, err := ledgerRep.Balance(ctx, usr.ID)
+	require.NoError(t, err)
+	assert.Equal(money.Amount(0), balance.Current)
+	assert.Equal(accrual, balance.Withdrawn)
+}
@@ -0,0 +1,24 @@
+package withdrawal
+
+import (
+	"net/url"
+
+	"github.com/nickzhog/gophermart/pkg/page"
+)
+
+var ErrBadFilter = page.ErrBadFilter
+
+// Filter ограничивает выборку списаний пользователя, нулевые поля не ограничивают.
+// Курсор страницы указывает на время и номер заказа последнего списания
+type Filter struct {
+	page.Params
+}
+
+// ParseFilter разбирает параметры запроса from, to, after и limit
+func ParseFilter(q url.Values) (Filter, error) {
+	p, err := page.ParseParams(q)
+	if err != nil {
+		return Filter{}, err
+	}
+	return Filter{Params: p}, nil
+}
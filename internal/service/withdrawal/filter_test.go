@@ -0,0 +1,68 @@
+package withdrawal
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/page"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	cursor := page.Cursor{At: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC), ID: "2377225624"}
+	tests := []struct {
+		name    string
+		query   string
+		want    Filter
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  Filter{},
+		},
+		{
+			name:  "all parameters",
+			query: "from=2022-10-01T00:00:00Z&to=2022-10-02T00:00:00Z&limit=10&after=" + cursor.String(),
+			want: Filter{page.Params{
+				From:  time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+				To:    time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC),
+				After: &cursor,
+				Limit: 10,
+			}},
+		},
+		{
+			name:    "bad date",
+			query:   "to=tomorrow",
+			wantErr: true,
+		},
+		{
+			name:    "empty date range",
+			query:   "from=2022-10-02T00:00:00Z&to=2022-10-02T00:00:00Z",
+			wantErr: true,
+		},
+		{
+			name:    "bad cursor",
+			query:   "after=bm9wZQ",
+			wantErr: true,
+		},
+		{
+			name:    "zero limit",
+			query:   "limit=0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			q, _ := url.ParseQuery(tt.query)
+
+			got, err := ParseFilter(q)
+			assert.Equal(tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(tt.want, got)
+			}
+		})
+	}
+}
@@ -1,16 +1,19 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi"
 	"github.com/nickzhog/gophermart/internal/repositories"
 	"github.com/nickzhog/gophermart/internal/service/user"
 	"github.com/nickzhog/gophermart/internal/service/withdrawal"
+	"github.com/nickzhog/gophermart/internal/web/response"
+	"github.com/nickzhog/gophermart/pkg/apperr"
 	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/page"
 )
 
 type handler struct {
@@ -33,135 +36,85 @@ func (h *handler) GetRouteGroup() func(r chi.Router) {
 	}
 }
 
-func (h *handler) writeError(w http.ResponseWriter, err string, code int) {
-	h.logger.Tracef("handler return error: %s, code: %v", err, code)
-	http.Error(w, err, code)
-}
-
-func (h *handler) writeAnswer(w http.ResponseWriter, ans string, code int) {
-	h.logger.Tracef("answer: %s, code: %v", ans, code)
-
-	w.WriteHeader(code)
-	w.Write([]byte(ans))
-}
-
 // получение текущего баланса счёта баллов лояльности пользователя
 func (h *handler) balanceHandler(w http.ResponseWriter, r *http.Request) {
 	usrID := user.GetUserIDFromRequest(r)
 
-	usr, err := h.User.FindByID(r.Context(), usrID)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	balance, err := usr.CalculateBalance(r.Context(), h.Order, h.Withdrawal)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	withdrawn, err := usr.CalculateWithdrawn(r.Context(), h.Withdrawal)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	m := make(map[string]interface{})
-	m["current"] = balance
-	m["withdrawn"] = withdrawn
-
-	w.Header().Set("Content-Type", "application/json")
-
-	data, err := json.Marshal(m)
+	balance, err := h.Ledger.Balance(r.Context(), usrID)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, err)
 		return
 	}
 
-	h.writeAnswer(w, string(data), http.StatusOK)
+	response.JSON(h.logger, w, r, http.StatusOK, balance)
 }
 
 // запрос на списание баллов с накопительного счёта в счёт оплаты нового заказа
 func (h *handler) withdrawActionHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusBadRequest)
+		response.Error(h.logger, w, r, apperr.BadRequest(err))
 		return
 	}
 	wReq, err := withdrawal.ParseWithdrawalRequest(body)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusUnprocessableEntity)
+		response.Error(h.logger, w, r, err)
 		return
 	}
 	h.logger.Tracef("withdrawal request: %+v", wReq)
 
 	usrID := user.GetUserIDFromRequest(r)
-	usr, err := h.User.FindByID(r.Context(), usrID)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	balance, err := usr.CalculateBalance(r.Context(), h.Order, h.Withdrawal)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if balance < wReq.Sum {
-		h.writeError(w, "not enough balance", http.StatusPaymentRequired)
-		return
-	}
-
 	wdl, err := withdrawal.NewWithdrawal(wReq.Order, usrID, wReq.Sum)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusUnprocessableEntity)
-		return
-	}
-	_, err = h.Withdrawal.FindByID(r.Context(), wdl.ID)
-	if !errors.Is(err, withdrawal.ErrNoRows) {
-		if errors.Is(err, withdrawal.ErrNoRows) {
-			h.writeError(w, "order already used", http.StatusConflict)
-			return
-		}
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, err)
 		return
 	}
 
-	err = h.Withdrawal.Create(r.Context(), &wdl)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+	// ErrInsufficientFunds даёт 402, ErrAlreadyExists 409
+	if err = h.Withdrawal.Create(r.Context(), &wdl); err != nil {
+		response.Error(h.logger, w, r, err)
 		return
 	}
 
 	h.logger.Tracef("new withdrawal: %+v", wdl)
 
-	h.writeAnswer(w, "withdrawal succeeded", http.StatusOK)
+	response.Text(h.logger, w, http.StatusOK, "withdrawal succeeded")
 }
 
 // получение информации о выводе средств с накопительного счёта пользователем
 func (h *handler) withdrawalsHandler(w http.ResponseWriter, r *http.Request) {
 	usrID := user.GetUserIDFromRequest(r)
-	withdrawals, err := h.Withdrawal.FindForUser(r.Context(), usrID)
+
+	filter, err := withdrawal.ParseFilter(r.URL.Query())
 	if err != nil {
-		if errors.Is(err, withdrawal.ErrNoRows) {
-			h.writeAnswer(w, "no orders", http.StatusNoContent)
-			return
-		}
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	// запрашиваем на одно списание больше, чтобы узнать, есть ли следующая страница
+	query := filter
+	if query.Limit > 0 {
+		query.Limit++
+	}
+	withdrawals, err := h.Withdrawal.FindForUser(r.Context(), usrID, query)
+	if err != nil && !errors.Is(err, withdrawal.ErrNoRows) {
+		response.Error(h.logger, w, r, err)
 		return
 	}
 	if len(withdrawals) < 1 {
-		h.writeAnswer(w, "no orders", http.StatusNoContent)
+		response.Text(h.logger, w, http.StatusNoContent, "no orders")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	data, err := json.Marshal(withdrawals)
-	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
-		return
+	if filter.Limit > 0 && len(withdrawals) > filter.Limit {
+		withdrawals = withdrawals[:filter.Limit]
+		last := withdrawals[len(withdrawals)-1]
+		response.SetNextPage(w, r, page.Cursor{At: last.ProcessedAt, ID: last.ID}.String())
 	}
 
-	h.writeAnswer(w, string(data), http.StatusOK)
+	// итоги по возвращённой странице, тело ответа остаётся массивом
+	w.Header().Set("X-Page-Count", strconv.Itoa(len(withdrawals)))
+	w.Header().Set("X-Page-Sum", withdrawal.SumForWithdrawals(withdrawals).String())
+
+	response.JSON(h.logger, w, r, http.StatusOK, withdrawals)
 }
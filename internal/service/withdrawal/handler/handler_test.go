@@ -4,20 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
-	"github.com/nickzhog/gophermart/internal/service/order"
-	mock_order "github.com/nickzhog/gophermart/internal/service/order/mocks"
-	"github.com/nickzhog/gophermart/internal/service/user"
-	mock_user "github.com/nickzhog/gophermart/internal/service/user/mocks"
+	"github.com/nickzhog/gophermart/internal/service/ledger"
+	mock_ledger "github.com/nickzhog/gophermart/internal/service/ledger/mocks"
 	"github.com/nickzhog/gophermart/internal/service/withdrawal"
 	mock_withdrawal "github.com/nickzhog/gophermart/internal/service/withdrawal/mocks"
 	"github.com/nickzhog/gophermart/internal/web/session"
 	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/money"
+	"github.com/nickzhog/gophermart/pkg/page"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,21 +28,13 @@ const (
 
 	alreadyUsedID = "used_id"
 
-	balance   float64 = 22
-	withdrawn float64 = 8
+	balance   money.Amount = 2200
+	withdrawn money.Amount = 800
 )
 
 var (
-	order1 = order.Order{ID: "5880182", UserID: validUsrID,
-		Status: order.StatusProcessed, Accrual: "10", AccrualFloat: 10, UploadAt: time.Now()}
-
-	order2 = order.Order{ID: "71476808630764", UserID: validUsrID,
-		Status: order.StatusProcessed, Accrual: "20", AccrualFloat: 20, UploadAt: time.Now()}
-
-	//
-
-	withdrawal1 = withdrawal.Withdrawal{ID: "123", UserID: validUsrID, Sum: "5", SumFloat: 5, ProcessedAt: time.Now()}
-	withdrawal2 = withdrawal.Withdrawal{ID: "321", UserID: validUsrID, Sum: "3", SumFloat: 3, ProcessedAt: time.Now()}
+	withdrawal1 = withdrawal.Withdrawal{ID: "123", UserID: validUsrID, Sum: 500, ProcessedAt: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)}
+	withdrawal2 = withdrawal.Withdrawal{ID: "321", UserID: validUsrID, Sum: 300, ProcessedAt: time.Date(2022, 10, 1, 12, 1, 0, 0, time.UTC)}
 )
 
 func prepareHandler(ctrl *gomock.Controller) *handler {
@@ -48,36 +42,34 @@ func prepareHandler(ctrl *gomock.Controller) *handler {
 		logger: logging.GetLogger(),
 	}
 
-	usrRep := mock_user.NewMockRepository(ctrl)
-	usrRep.EXPECT().FindByID(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, id string) (user.User, error) {
-			if id == validUsrID {
-				return user.User{ID: validUsrID}, nil
-			}
-			return user.User{}, user.ErrNoRows
-		})
-	h.Repositories.User = usrRep
-
-	orderRep := mock_order.NewMockRepository(ctrl)
-	orderRep.EXPECT().FindForUser(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, usrID string) ([]order.Order, error) {
+	ledgerRep := mock_ledger.NewMockRepository(ctrl)
+	ledgerRep.EXPECT().Balance(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID string) (ledger.Balance, error) {
 			if usrID == validUsrID {
-				return []order.Order{order1, order2}, nil
+				return ledger.Balance{UserID: usrID, Current: balance, Withdrawn: withdrawn}, nil
 			}
-
-			return []order.Order{}, order.ErrNoRows
+			return ledger.Balance{}, errors.New("connection refused")
 		})
 
-	h.Repositories.Order = orderRep
+	h.Repositories.Ledger = ledgerRep
 
 	withdrawalRep := mock_withdrawal.NewMockRepository(ctrl)
-	withdrawalRep.EXPECT().FindForUser(gomock.Any(), gomock.Any()).AnyTimes().
-		DoAndReturn(func(ctx context.Context, usrID string) ([]withdrawal.Withdrawal, error) {
-			if usrID == validUsrID {
-				return []withdrawal.Withdrawal{withdrawal1, withdrawal2}, nil
+	withdrawalRep.EXPECT().FindForUser(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID string, filter withdrawal.Filter) ([]withdrawal.Withdrawal, error) {
+			if usrID != validUsrID {
+				return []withdrawal.Withdrawal{}, withdrawal.ErrNoRows
 			}
 
-			return []withdrawal.Withdrawal{}, withdrawal.ErrNoRows
+			wdls := []withdrawal.Withdrawal{withdrawal1, withdrawal2}
+			if filter.After != nil {
+				for len(wdls) > 0 && !wdls[0].ProcessedAt.After(filter.After.At) {
+					wdls = wdls[1:]
+				}
+			}
+			if filter.Limit > 0 && len(wdls) > filter.Limit {
+				wdls = wdls[:filter.Limit]
+			}
+			return wdls, nil
 		})
 
 	withdrawalRep.EXPECT().FindByID(gomock.Any(), gomock.Any()).AnyTimes().
@@ -91,6 +83,12 @@ func prepareHandler(ctrl *gomock.Controller) *handler {
 
 	withdrawalRep.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().
 		DoAndReturn(func(ctx context.Context, wdl *withdrawal.Withdrawal) error {
+			if wdl.ID == alreadyUsedID {
+				return withdrawal.ErrAlreadyExists
+			}
+			if wdl.Sum > balance {
+				return withdrawal.ErrInsufficientFunds
+			}
 			return nil
 		})
 
@@ -100,8 +98,8 @@ func prepareHandler(ctrl *gomock.Controller) *handler {
 }
 
 type Balance struct {
-	Balance   float64 `json:"current,omitempty"`
-	Withdrawn float64 `json:"withdrawn,omitempty"`
+	Balance   money.Amount `json:"current,omitempty"`
+	Withdrawn money.Amount `json:"withdrawn,omitempty"`
 }
 
 func Test_handler_balanceHandler(t *testing.T) {
@@ -159,7 +157,7 @@ func Test_handler_withdrawActionHandler(t *testing.T) {
 	}{
 		{
 			name:        "positive case",
-			requestBody: []byte(`{"order":"123321", "sum":20}`),
+			requestBody: []byte(`{"order":"2377225624", "sum":20}`),
 			wantStatus:  http.StatusOK,
 		},
 		{
@@ -167,9 +165,24 @@ func Test_handler_withdrawActionHandler(t *testing.T) {
 			requestBody: []byte(`{"orde`),
 			wantStatus:  http.StatusUnprocessableEntity,
 		},
+		{
+			name:        "negative sum",
+			requestBody: []byte(`{"order":"2377225624", "sum":-20}`),
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "sum with more than two fractional digits",
+			requestBody: []byte(`{"order":"2377225624", "sum":20.001}`),
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "wrong order (luhn)",
+			requestBody: []byte(`{"order":"2377225625", "sum":20}`),
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
 		{
 			name:        "sum more than balance",
-			requestBody: []byte(`{"order":"123321", "sum":120}`),
+			requestBody: []byte(`{"order":"2377225624", "sum":120}`),
 			wantStatus:  http.StatusPaymentRequired,
 		},
 	}
@@ -200,12 +213,37 @@ func Test_handler_withdrawalsHandler(t *testing.T) {
 	tests := []struct {
 		name       string
 		usrID      string
+		query      string
 		wantStatus int
+		wantSum    string
+		wantNext   bool
 	}{
 		{
 			name:       "positive case",
 			usrID:      validUsrID,
 			wantStatus: http.StatusOK,
+			wantSum:    "8",
+		},
+		{
+			name:       "first page",
+			usrID:      validUsrID,
+			query:      "?limit=1",
+			wantStatus: http.StatusOK,
+			wantSum:    "5",
+			wantNext:   true,
+		},
+		{
+			name:       "next page",
+			usrID:      validUsrID,
+			query:      "?limit=1&after=" + page.Cursor{At: withdrawal1.ProcessedAt, ID: withdrawal1.ID}.String(),
+			wantStatus: http.StatusOK,
+			wantSum:    "3",
+		},
+		{
+			name:       "bad date range",
+			usrID:      validUsrID,
+			query:      "?from=2022-10-02T00:00:00Z&to=2022-10-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
 		},
 		{
 			name:       "wrong user",
@@ -222,7 +260,7 @@ func Test_handler_withdrawalsHandler(t *testing.T) {
 
 			h := prepareHandler(ctrl)
 
-			request := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals", bytes.NewBuffer(nil))
+			request := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals"+tt.query, bytes.NewBuffer(nil))
 			request = session.PutSessionDataInRequest(request, "session", tt.usrID)
 
 			w := httptest.NewRecorder()
@@ -232,10 +270,13 @@ func Test_handler_withdrawalsHandler(t *testing.T) {
 			defer res.Body.Close()
 
 			assert.Equal(tt.wantStatus, res.StatusCode)
+			assert.Equal(tt.wantNext, res.Header.Get("X-Next-Cursor") != "")
 			if res.StatusCode == http.StatusOK {
+				assert.Equal(tt.wantSum, res.Header.Get("X-Page-Sum"))
 				var withdrawals []withdrawal.Withdrawal
 				err := json.NewDecoder(res.Body).Decode(&withdrawals)
 				assert.NoError(err)
+				assert.Equal(strconv.Itoa(len(withdrawals)), res.Header.Get("X-Page-Count"))
 			}
 		})
 	}
@@ -65,16 +65,16 @@ func (mr *MockRepositoryMockRecorder) FindByID(ctx, id interface{}) *gomock.Call
 }
 
 // FindForUser mocks base method.
-func (m *MockRepository) FindForUser(ctx context.Context, usrID string) ([]withdrawal.Withdrawal, error) {
+func (m *MockRepository) FindForUser(ctx context.Context, usrID string, filter withdrawal.Filter) ([]withdrawal.Withdrawal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindForUser", ctx, usrID)
+	ret := m.ctrl.Call(m, "FindForUser", ctx, usrID, filter)
 	ret0, _ := ret[0].([]withdrawal.Withdrawal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindForUser indicates an expected call of FindForUser.
-func (mr *MockRepositoryMockRecorder) FindForUser(ctx, usrID interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FindForUser(ctx, usrID, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForUser", reflect.TypeOf((*MockRepository)(nil).FindForUser), ctx, usrID)
-}
\ No newline at end of file
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForUser", reflect.TypeOf((*MockRepository)(nil).FindForUser), ctx, usrID, filter)
+}
@@ -3,7 +3,12 @@ package withdrawal
 import "context"
 
 type Repository interface {
+	// Create атомарно списывает сумму с баланса пользователя и сохраняет списание.
+	// Возвращает ErrInsufficientFunds, если баланса недостаточно,
+	// и ErrAlreadyExists, если списание по этому заказу уже было.
+	// Событие списания записывается в outbox вебхуков в той же транзакции
 	Create(ctx context.Context, w *Withdrawal) error
-	FindForUser(ctx context.Context, usrID string) ([]Withdrawal, error)
+	// FindForUser возвращает списания пользователя по возрастанию времени списания
+	FindForUser(ctx context.Context, usrID string, filter Filter) ([]Withdrawal, error)
 	FindByID(ctx context.Context, id string) (Withdrawal, error)
 }
@@ -2,59 +2,65 @@ package withdrawal
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"strconv"
 	"time"
+
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/pkg/apperr"
+	"github.com/nickzhog/gophermart/pkg/money"
 )
 
 type Withdrawal struct {
-	ID          string    `json:"order,omitempty"`
-	UserID      string    `json:"-"`
-	Sum         string    `json:"-"`
-	SumFloat    float64   `json:"sum,omitempty"`
-	ProcessedAt time.Time `json:"processed_at,omitempty"`
+	ID          string       `json:"order,omitempty"`
+	UserID      string       `json:"-"`
+	Sum         money.Amount `json:"sum,omitempty"`
+	ProcessedAt time.Time    `json:"processed_at,omitempty"`
 }
 
 type WithdrawalRequest struct {
-	Order string  `json:"order,omitempty"`
-	Sum   float64 `json:"sum,omitempty"`
+	Order string       `json:"order,omitempty"`
+	Sum   money.Amount `json:"sum,omitempty"`
 }
 
-var ErrNoRows = errors.New("withdrawal not found")
+var (
+	ErrNoRows            = apperr.New(apperr.ErrNotFound, "withdrawal not found")
+	ErrAlreadyExists     = apperr.New(apperr.ErrConflict, "withdrawal for that order already exists")
+	ErrInsufficientFunds = apperr.New(apperr.ErrInsufficientFunds, "not enough balance")
+	ErrInvalidSum        = apperr.New(apperr.ErrValidation, "sum must be positive")
+	ErrInvalidOrder      = apperr.New(apperr.ErrValidation, "invalid order number")
+)
 
 func ParseWithdrawalRequest(data []byte) (WithdrawalRequest, error) {
 	var wr WithdrawalRequest
 	err := json.Unmarshal(data, &wr)
 	if err != nil {
-		return WithdrawalRequest{}, err
+		return WithdrawalRequest{}, apperr.Validation(err)
+	}
+	if wr.Sum <= 0 {
+		return WithdrawalRequest{}, ErrInvalidSum
 	}
 	return wr, nil
 }
 
-func NewWithdrawal(orderID, usrID string, sum float64) (Withdrawal, error) {
-	w := Withdrawal{
-		ID:       orderID,
-		UserID:   usrID,
-		Sum:      fmt.Sprintf("%g", sum),
-		SumFloat: sum,
-	}
-	idInt, err := strconv.Atoi(orderID)
+// NewWithdrawal проверяет номер заказа по тем же правилам, что и при загрузке заказа
+func NewWithdrawal(orderID, usrID string, sum money.Amount) (Withdrawal, error) {
+	number, err := order.ParseNumber(orderID)
 	if err != nil {
-		return Withdrawal{}, err
+		return Withdrawal{}, fmt.Errorf("%w: %s", ErrInvalidOrder, err.Error())
 	}
 
-	if idInt < 1 {
-		return Withdrawal{}, errors.New("wrong order")
+	w := Withdrawal{
+		ID:     number,
+		UserID: usrID,
+		Sum:    sum,
 	}
-
 	return w, nil
 }
 
-func SumForWithdrawals(wdls []Withdrawal) float64 {
-	answer := 0.0
+func SumForWithdrawals(wdls []Withdrawal) money.Amount {
+	var answer money.Amount
 	for _, v := range wdls {
-		answer += v.SumFloat
+		answer += v.Sum
 	}
 	return answer
 }
@@ -3,6 +3,7 @@ package withdrawal
 import (
 	"testing"
 
+	"github.com/nickzhog/gophermart/pkg/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -10,18 +11,31 @@ func TestNewWithdrawal(t *testing.T) {
 	type args struct {
 		orderID string
 		usrID   string
-		sum     float64
+		sum     money.Amount
 	}
 	tests := []struct {
 		name    string
 		args    args
+		want    string
 		wantErr bool
 	}{
 		{
 			name:    "positive case",
-			args:    args{orderID: "12321"},
+			args:    args{orderID: "2377225624"},
+			want:    "2377225624",
 			wantErr: false,
 		},
+		{
+			name:    "longer than int64",
+			args:    args{orderID: " 123456789012345678901234567891\n"},
+			want:    "123456789012345678901234567891",
+			wantErr: false,
+		},
+		{
+			name:    "wrong luhn",
+			args:    args{orderID: "2377225625"},
+			wantErr: true,
+		},
 		{
 			name:    "wrong order id",
 			args:    args{orderID: "abc"},
@@ -36,8 +50,55 @@ func TestNewWithdrawal(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			assert := assert.New(t)
-			_, err := NewWithdrawal(tt.args.orderID, tt.args.usrID, tt.args.sum)
+			got, err := NewWithdrawal(tt.args.orderID, tt.args.usrID, tt.args.sum)
+			assert.Equal(tt.wantErr, err != nil)
+			assert.Equal(tt.want, got.ID)
+			if err != nil {
+				assert.ErrorIs(err, ErrInvalidOrder)
+			}
+		})
+	}
+}
+
+func TestParseWithdrawalRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    WithdrawalRequest
+		wantErr bool
+	}{
+		{
+			name: "positive case",
+			data: []byte(`{"order":"2377225624","sum":751.5}`),
+			want: WithdrawalRequest{Order: "2377225624", Sum: 75150},
+		},
+		{
+			name:    "negative sum",
+			data:    []byte(`{"order":"2377225624","sum":-1}`),
+			wantErr: true,
+		},
+		{
+			name:    "zero sum",
+			data:    []byte(`{"order":"2377225624","sum":0}`),
+			wantErr: true,
+		},
+		{
+			name:    "sum past int64",
+			data:    []byte(`{"order":"2377225624","sum":922337203685477581}`),
+			wantErr: true,
+		},
+		{
+			name:    "more than two fractional digits",
+			data:    []byte(`{"order":"2377225624","sum":0.001}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, err := ParseWithdrawalRequest(tt.data)
 			assert.Equal(tt.wantErr, err != nil)
+			assert.Equal(tt.want, got)
 		})
 	}
 }
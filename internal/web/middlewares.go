@@ -2,39 +2,131 @@ package web
 
 import (
 	"compress/gzip"
+	"crypto/subtle"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 
 	"github.com/nickzhog/gophermart/internal/repositories"
+	"github.com/nickzhog/gophermart/internal/web/response"
 	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/pkg/apperr"
 	"github.com/nickzhog/gophermart/pkg/logging"
 )
 
 func SessionMiddleware(logger *logging.Logger, reps repositories.Repositories) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			sID, err := session.GetSessionFromCookie(r)
+			sID, err := session.GetSessionFromRequest(r)
 			if err != nil {
-				logger.Error(err)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				logger.Tracef("no session: %s", err.Error())
+				response.Error(logger, w, r, session.ErrUnauthorized)
 				return
 			}
-			s, err := reps.Session.FindByID(r.Context(), sID)
+			// пользователь сессии существует: в БД это гарантирует внешний ключ,
+			// у токена подпись, поэтому отдельный запрос пользователя не нужен
+			s, err := reps.Session.FindByID(r.Context(), sID, session.ClientFromRequest(r))
 			if err != nil {
-				logger.Error(err)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				logger.Tracef("session rejected: %s", err.Error())
+				response.Error(logger, w, r, session.ErrUnauthorized)
 				return
 			}
+			r = session.PutSessionDataInRequest(r, s.ID, s.UserID)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var ErrAdminUnauthorized = apperr.New(apperr.ErrUnauthorized, "admin token required")
+
+// AdminMiddleware пропускает запросы с заголовком Authorization: Bearer <token>
+func AdminMiddleware(logger *logging.Logger, token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if token == "" || !strings.HasPrefix(auth, "Bearer ") ||
+				subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+				response.Error(logger, w, r, ErrAdminUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ParseTrustedProxies разбирает адреса и подсети доверенных прокси через запятую
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			ip := net.ParseIP(part)
+			if ip == nil {
+				return nil, fmt.Errorf("bad trusted proxy %q", part)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			part = fmt.Sprintf("%s/%d", part, bits)
+		}
+		_, n, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("bad trusted proxy %q: %w", part, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// RealIP подставляет в RemoteAddr адрес клиента из X-Forwarded-For или X-Real-IP,
+// только если запрос пришёл от доверенного прокси. В X-Forwarded-For адреса
+// проверяются справа налево, клиентом считается первый недоверенный
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
 
-			usr, err := reps.User.FindByID(r.Context(), s.UserID)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, _, err := net.SplitHostPort(r.RemoteAddr)
 			if err != nil {
-				logger.Error(err)
-				reps.Session.Disable(r.Context(), sID)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				peer = r.RemoteAddr
+			}
+			if !isTrusted(peer) {
+				next.ServeHTTP(w, r)
 				return
 			}
-			r = session.PutSessionDataInRequest(r, s.ID, usr.ID)
+
+			client := ""
+			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+				hops := strings.Split(xff, ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					client = strings.TrimSpace(hops[i])
+					if !isTrusted(client) {
+						break
+					}
+				}
+			} else {
+				client = strings.TrimSpace(r.Header.Get("X-Real-IP"))
+			}
+			if net.ParseIP(client) != nil {
+				r.RemoteAddr = client
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -51,6 +143,16 @@ func (w gzipWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
+// Flush отправляет сжатые данные клиенту сразу, это нужно потокам событий
+func (w gzipWriter) Flush() {
+	if f, ok := w.Writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func gzipCompress(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "untrusted peer can not spoof",
+			remoteAddr: "203.0.113.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.1"},
+			want:       "203.0.113.5:1234",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client prepends fake hop",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.7"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "real ip from trusted proxy",
+			remoteAddr: "192.0.2.10:1234",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "garbage header",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-For": "not-an-ip"},
+			want:       "10.1.2.3:1234",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				request.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), request)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies("")
+	assert.NoError(t, err)
+	assert.Empty(t, nets)
+
+	nets, err = ParseTrustedProxies("::1,172.16.0.0/12")
+	assert.NoError(t, err)
+	assert.Len(t, nets, 2)
+
+	_, err = ParseTrustedProxies("proxy.local")
+	assert.Error(t, err)
+}
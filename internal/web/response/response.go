@@ -0,0 +1,124 @@
+// Package response общий для обработчиков вывод ответов: текст, JSON
+// и ошибки в формате RFC 7807 application/problem+json
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/nickzhog/gophermart/pkg/apperr"
+	"github.com/nickzhog/gophermart/pkg/logging"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem тело ответа с ошибкой по RFC 7807
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// StatusCode возвращает код ответа для вида ошибки err, неизвестные ошибки дают 500
+func StatusCode(err error) int {
+	switch apperr.KindOf(err) {
+	case apperr.ErrBadRequest:
+		return http.StatusBadRequest
+	case apperr.ErrValidation:
+		return http.StatusUnprocessableEntity
+	case apperr.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case apperr.ErrForbidden:
+		return http.StatusForbidden
+	case apperr.ErrNotFound:
+		return http.StatusNotFound
+	case apperr.ErrConflict:
+		return http.StatusConflict
+	case apperr.ErrInsufficientFunds:
+		return http.StatusPaymentRequired
+	case apperr.ErrTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case apperr.ErrTooManyRequests:
+		return http.StatusTooManyRequests
+	case apperr.ErrNotImplemented:
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// Error отвечает ошибкой с кодом по её виду. Текст ошибок без вида,
+// например ошибок БД, клиенту не показывается, а только пишется в лог
+func Error(logger *logging.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	if ctxErr := r.Context().Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+		logger.Tracef("request canceled: %s", err.Error())
+		return
+	}
+
+	code := StatusCode(err)
+	detail := err.Error()
+	if code == http.StatusInternalServerError {
+		logger.Errorf("request %s: %s", middleware.GetReqID(r.Context()), err.Error())
+		detail = "internal server error"
+	} else {
+		logger.Tracef("handler return error: %s, code: %v", err.Error(), code)
+	}
+
+	p := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(code),
+		Status:    code,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// Text отвечает текстом
+func Text(logger *logging.Logger, w http.ResponseWriter, code int, ans string) {
+	logger.Tracef("answer: %s, code: %v", ans, code)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write([]byte(ans))
+}
+
+// JSON отвечает значением v в JSON
+func JSON(logger *logging.Logger, w http.ResponseWriter, r *http.Request, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		Error(logger, w, r, err)
+		return
+	}
+	logger.Tracef("answer: %s, code: %v", data, code)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// SetNextPage сообщает клиенту курсор следующей страницы в заголовках X-Next-Cursor и Link
+func SetNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	next := *r.URL
+	q := next.Query()
+	q.Set("after", cursor)
+	next.RawQuery = q.Encode()
+
+	w.Header().Set("X-Next-Cursor", cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
@@ -0,0 +1,101 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/nickzhog/gophermart/internal/service/order"
+	"github.com/nickzhog/gophermart/internal/service/user"
+	"github.com/nickzhog/gophermart/internal/service/withdrawal"
+	"github.com/nickzhog/gophermart/pkg/apperr"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: order.ErrNoRows, want: http.StatusNotFound},
+		{err: fmt.Errorf("%w: luhn check fail", order.ErrInvalidNumber), want: http.StatusUnprocessableEntity},
+		{err: order.ErrOwnedByAnotherUser, want: http.StatusConflict},
+		{err: order.ErrBadFilter, want: http.StatusBadRequest},
+		{err: withdrawal.ErrInsufficientFunds, want: http.StatusPaymentRequired},
+		{err: withdrawal.ErrAlreadyExists, want: http.StatusConflict},
+		{err: user.ErrLoginTaken, want: http.StatusConflict},
+		{err: user.ErrWrongCredentials, want: http.StatusUnauthorized},
+		{err: apperr.BadRequest(user.ErrWeakPassword), want: http.StatusBadRequest},
+		{err: errors.New("connection refused"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.want, StatusCode(tt.err))
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+	}{
+		{
+			name:       "domain error",
+			err:        withdrawal.ErrInsufficientFunds,
+			wantStatus: http.StatusPaymentRequired,
+			wantDetail: "not enough balance",
+		},
+		{
+			name:       "internal error is not shown",
+			err:        errors.New(`SQL Error: relation "orders" does not exist`),
+			wantStatus: http.StatusInternalServerError,
+			wantDetail: "internal server error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var r *http.Request
+			h := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				r = req
+				Error(logging.GetLogger(), w, req, tt.err)
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/orders", nil))
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+			assert.Equal(ProblemContentType, res.Header.Get("Content-Type"))
+
+			var p Problem
+			assert.NoError(json.NewDecoder(res.Body).Decode(&p))
+			assert.Equal(Problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(tt.wantStatus),
+				Status:    tt.wantStatus,
+				Detail:    tt.wantDetail,
+				Instance:  "/api/user/orders",
+				RequestID: middleware.GetReqID(r.Context()),
+			}, p)
+			assert.NotEmpty(p.RequestID)
+		})
+	}
+}
+
+func TestSetNextPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetNextPage(w, httptest.NewRequest(http.MethodGet, "/api/user/orders?limit=10&after=old", nil), "next")
+
+	assert.Equal(t, "next", w.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, `</api/user/orders?after=next&limit=10>; rel="next"`, w.Header().Get("Link"))
+}
@@ -9,22 +9,59 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/orderevents"
 	"github.com/nickzhog/gophermart/internal/repositories"
+	"github.com/nickzhog/gophermart/internal/service/loginattempt"
 	orderHandler "github.com/nickzhog/gophermart/internal/service/order/handler"
+	"github.com/nickzhog/gophermart/internal/service/user"
 	userHandler "github.com/nickzhog/gophermart/internal/service/user/handler"
+	webhookHandler "github.com/nickzhog/gophermart/internal/service/webhook/handler"
 	withdrawalHandler "github.com/nickzhog/gophermart/internal/service/withdrawal/handler"
+	"github.com/nickzhog/gophermart/internal/web/session"
+	sessionHandler "github.com/nickzhog/gophermart/internal/web/session/handler"
 	"github.com/nickzhog/gophermart/pkg/logging"
 )
 
-func PrepareServer(logger *logging.Logger, cfg *config.Config, reps repositories.Repositories) *http.Server {
-	orderHandler := orderHandler.NewHandler(logger, reps)
-	userHandler := userHandler.NewHandler(logger, reps)
+func PrepareServer(logger *logging.Logger, cfg *config.Config, reps repositories.Repositories,
+	events *orderevents.Broker) *http.Server {
+	orderHandler := orderHandler.NewHandler(logger, reps, orderHandler.Options{
+		BatchLimit: cfg.Settings.OrdersBatchLimit,
+		Events:     events,
+	})
+	cookie := session.CookieOptions{
+		Secure: cfg.Settings.SessionCookieSecure,
+		MaxAge: cfg.Settings.SessionTTL,
+	}
+	userHandler := userHandler.NewHandler(logger, reps, userHandler.Options{
+		Cookie: cookie,
+		PasswordPolicy: user.PasswordPolicy{
+			MinLength:   cfg.Settings.PasswordMinLength,
+			MinClasses:  cfg.Settings.PasswordMinClasses,
+			CheckCommon: cfg.Settings.PasswordCheckCommon,
+		},
+		LoginPolicy: loginattempt.Policy{
+			MaxLoginFailures: cfg.Settings.LoginMaxFailures,
+			MaxIPFailures:    cfg.Settings.LoginMaxIPFailures,
+			Window:           cfg.Settings.LoginFailureWindow,
+			LockoutBase:      cfg.Settings.LoginLockoutBase,
+			LockoutMax:       cfg.Settings.LoginLockoutMax,
+		},
+	})
+	sessionHandler := sessionHandler.NewHandler(logger, reps, cookie)
 	withdrawalHander := withdrawalHandler.NewHandler(logger, reps)
+	webhookHandler := webhookHandler.NewHandler(logger, reps)
+
+	trustedProxies, err := ParseTrustedProxies(cfg.Settings.TrustedProxies)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	// заголовки адреса клиента принимаются только от доверенных прокси, иначе их
+	// подделкой можно обойти ограничение попыток входа по адресу
+	r.Use(RealIP(trustedProxies))
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
 
@@ -40,14 +77,28 @@ func PrepareServer(logger *logging.Logger, cfg *config.Config, reps repositories
 
 				r.Group(orderHandler.GetRouteGroup())
 				r.Group(withdrawalHander.GetRouteGroup())
+				r.Group(sessionHandler.GetRouteGroup())
+				r.Group(userHandler.GetPrivateRouteGroup())
 			})
 		})
+
+		// без токена администратора маршруты не подключаются
+		if cfg.Settings.AdminToken != "" {
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(AdminMiddleware(logger, cfg.Settings.AdminToken))
+
+				r.Group(webhookHandler.GetRouteGroup())
+			})
+		}
 	})
 
-	return &http.Server{
+	srv := &http.Server{
 		Addr:    cfg.Settings.RunAddress,
 		Handler: r,
 	}
+	// потоки событий сами не завершаются, их закрывает остановка брокера
+	srv.RegisterOnShutdown(events.Close)
+	return srv
 }
 
 func Serve(ctx context.Context, logger *logging.Logger, srv *http.Server) (err error) {
@@ -4,29 +4,48 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/nickzhog/gophermart/internal/web/session"
 	"github.com/nickzhog/gophermart/pkg/logging"
 	"github.com/nickzhog/gophermart/pkg/postgres"
 )
 
+const (
+	// touchInterval ограничивает частоту записи last_seen_at при активности
+	touchInterval = time.Minute
+
+	columns = `id, public_id, user_id, create_at, last_seen_at, is_active, ip, user_agent`
+
+	// alive условие действующей сессии, $2 абсолютный таймаут, $3 таймаут бездействия в секундах
+	alive = `is_active = true
+			AND create_at > CURRENT_TIMESTAMP - make_interval(secs => $2::DOUBLE PRECISION)
+			AND last_seen_at > CURRENT_TIMESTAMP - make_interval(secs => $3::DOUBLE PRECISION)`
+)
+
+func scan(row pgx.Row) (session.Session, error) {
+	var s session.Session
+	err := row.Scan(&s.ID, &s.PublicID, &s.UserID, &s.CreateAt, &s.LastSeenAt, &s.IsActive, &s.IP, &s.UserAgent)
+	return s, err
+}
+
 type repository struct {
-	client postgres.Client
-	logger *logging.Logger
+	client      postgres.Client
+	logger      *logging.Logger
+	ttl         time.Duration
+	idleTimeout time.Duration
 }
 
-func (r *repository) Create(ctx context.Context, usrID string) (session.Session, error) {
+func (r *repository) Create(ctx context.Context, usrID string, c session.Client) (session.Session, error) {
 	q := `
 	INSERT INTO public.sessions 
-		(user_id) 
+		(user_id, ip, user_agent) 
 	VALUES 
-		($1) 
-	RETURNING id, user_id, create_at, is_active
-	`
-	var s session.Session
-	err := r.client.QueryRow(ctx, q, usrID).
-		Scan(&s.ID, &s.UserID, &s.CreateAt, &s.IsActive)
+		($1, $2, $3) 
+	RETURNING ` + columns
+	s, err := scan(r.client.QueryRow(ctx, q, usrID, c.IP, c.UserAgent))
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -41,26 +60,101 @@ func (r *repository) Create(ctx context.Context, usrID string) (session.Session,
 	return s, nil
 }
 
-func (r *repository) FindByID(ctx context.Context, id string) (session.Session, error) {
+// FindByID находит сессию, не истёкшую ни по абсолютному таймауту, ни по бездействию,
+// и в том же запросе продлевает её, если last_seen_at старше touchInterval
+// или клиент сменил адрес либо агент
+func (r *repository) FindByID(ctx context.Context, id string, c session.Client) (session.Session, error) {
 	q := `
-	SELECT
-		id, user_id, create_at, is_active
-	FROM 
-		public.sessions
-	WHERE 
-		id = $1 and is_active = true
+	WITH s AS (
+		SELECT ` + columns + `
+		FROM 
+			public.sessions
+		WHERE 
+			id = $1 
+			AND ` + alive + `
+	), touched AS (
+		UPDATE 
+			public.sessions 
+		SET 
+			last_seen_at = CURRENT_TIMESTAMP,
+			ip = $5,
+			user_agent = $6
+		FROM s
+		WHERE 
+			sessions.id = s.id 
+			AND (s.last_seen_at < CURRENT_TIMESTAMP - make_interval(secs => $4::DOUBLE PRECISION)
+				OR s.ip <> $5 OR s.user_agent <> $6)
+	)
+	SELECT ` + columns + ` FROM s
 	`
-	var s session.Session
-	err := r.client.QueryRow(ctx, q, id).
-		Scan(&s.ID, &s.UserID, &s.CreateAt, &s.IsActive)
-
+	s, err := scan(r.client.QueryRow(ctx, q, id,
+		r.ttl.Seconds(), r.idleTimeout.Seconds(), touchInterval.Seconds(), c.IP, c.UserAgent))
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return session.Session{}, session.ErrNoRows
+		}
 		return session.Session{}, err
 	}
 
 	return s, nil
 }
 
+func (r *repository) Check(ctx context.Context, id string) error {
+	q := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM 
+			public.sessions
+		WHERE 
+			id = $1 
+			AND ` + alive + `
+	)
+	`
+	var ok bool
+	if err := r.client.QueryRow(ctx, q, id, r.ttl.Seconds(), r.idleTimeout.Seconds()).Scan(&ok); err != nil {
+		return err
+	}
+	if !ok {
+		return session.ErrNoRows
+	}
+	return nil
+}
+
+func (r *repository) FindForUser(ctx context.Context, usrID string) ([]session.Session, error) {
+	q := `
+		SELECT ` + columns + `
+		FROM 
+			public.sessions
+		WHERE 
+			user_id = $1 
+			AND ` + alive + `
+		ORDER BY last_seen_at DESC
+	`
+	rows, err := r.client.Query(ctx, q, usrID, r.ttl.Seconds(), r.idleTimeout.Seconds())
+	if err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]session.Session, 0)
+	for rows.Next() {
+		s, err := scan(rows)
+		if err != nil {
+			r.logger.Error(err)
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
 func (r *repository) Disable(ctx context.Context, id string) error {
 	q := `
 		UPDATE 
@@ -74,10 +168,77 @@ func (r *repository) Disable(ctx context.Context, id string) error {
 	return err
 }
 
-func NewRepository(client postgres.Client, logger *logging.Logger) session.Repository {
+func (r *repository) DisableByPublicID(ctx context.Context, usrID, publicID string) error {
+	q := `
+		UPDATE 
+			public.sessions 
+		SET
+			is_active = false
+		WHERE 
+			user_id = $1 
+			AND public_id = $2::UUID
+			AND is_active = true
+	`
+	tag, err := r.client.Exec(ctx, q, usrID, publicID)
+	if err != nil {
+		// идентификатор не UUID: такой сессии быть не может
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == postgres.InvalidTextRepresentation {
+			return session.ErrNoRows
+		}
+		r.logger.Error(err)
+		return err
+	}
+	if tag.RowsAffected() < 1 {
+		return session.ErrNoRows
+	}
+	return nil
+}
+
+func (r *repository) DisableForUser(ctx context.Context, usrID, keepID string) (int64, error) {
+	q := `
+		UPDATE 
+			public.sessions 
+		SET
+			is_active = false
+		WHERE 
+			user_id = $1 
+			AND id::TEXT <> $2
+			AND is_active = true
+	`
+	tag, err := r.client.Exec(ctx, q, usrID, keepID)
+	if err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *repository) PurgeExpired(ctx context.Context) (int64, error) {
+	q := `
+		DELETE FROM 
+			public.sessions 
+		WHERE 
+			is_active = false
+			OR create_at <= CURRENT_TIMESTAMP - make_interval(secs => $1::DOUBLE PRECISION)
+			OR last_seen_at <= CURRENT_TIMESTAMP - make_interval(secs => $2::DOUBLE PRECISION)
+	`
+	tag, err := r.client.Exec(ctx, q, r.ttl.Seconds(), r.idleTimeout.Seconds())
+	if err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// NewRepository хранит сессии в БД: ttl ограничивает время жизни сессии,
+// idleTimeout время без активности
+func NewRepository(client postgres.Client, logger *logging.Logger, ttl, idleTimeout time.Duration) session.Repository {
 
 	return &repository{
-		client: client,
-		logger: logger,
+		client:      client,
+		logger:      logger,
+		ttl:         ttl,
+		idleTimeout: idleTimeout,
 	}
 }
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/dbtest"
+	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_FindByID(t *testing.T) {
+	ctx, pool := dbtest.Connect(t)
+	usr := dbtest.NewUser(ctx, t, pool, "session-find")
+
+	rep := NewRepository(pool, logging.GetLogger(), time.Hour, time.Minute*10)
+	client := session.Client{IP: "192.0.2.1", UserAgent: "test"}
+
+	// age сдвигает время создания и последней активности сессии в прошлое
+	age := func(t *testing.T, id string, created, seen time.Duration) {
+		q := `
+			UPDATE public.sessions
+			SET
+				create_at = CURRENT_TIMESTAMP - make_interval(secs => $2::DOUBLE PRECISION),
+				last_seen_at = CURRENT_TIMESTAMP - make_interval(secs => $3::DOUBLE PRECISION)
+			WHERE id = $1
+		`
+		_, err := pool.Exec(ctx, q, id, created.Seconds(), seen.Seconds())
+		require.NoError(t, err)
+	}
+	lastSeen := func(t *testing.T, id string) time.Time {
+		var seen time.Time
+		err := pool.QueryRow(ctx, `SELECT last_seen_at FROM public.sessions WHERE id = $1`, id).Scan(&seen)
+		require.NoError(t, err)
+		return seen
+	}
+	create := func(t *testing.T) session.Session {
+		s, err := rep.Create(ctx, usr.ID, client)
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("active", func(t *testing.T) {
+		s := create(t)
+		got, err := rep.FindByID(ctx, s.ID, client)
+		require.NoError(t, err)
+		assert.Equal(t, usr.ID, got.UserID)
+		assert.Equal(t, s.PublicID, got.PublicID)
+	})
+
+	t.Run("ttl expired", func(t *testing.T) {
+		s := create(t)
+		age(t, s.ID, time.Hour+time.Minute, 0)
+		_, err := rep.FindByID(ctx, s.ID, client)
+		assert.ErrorIs(t, err, session.ErrNoRows)
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		s := create(t)
+		age(t, s.ID, time.Minute*11, time.Minute*11)
+		_, err := rep.FindByID(ctx, s.ID, client)
+		assert.ErrorIs(t, err, session.ErrNoRows)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := create(t)
+		require.NoError(t, rep.Disable(ctx, s.ID))
+		_, err := rep.FindByID(ctx, s.ID, client)
+		assert.ErrorIs(t, err, session.ErrNoRows)
+	})
+
+	t.Run("recent activity is not touched", func(t *testing.T) {
+		s := create(t)
+		age(t, s.ID, time.Second*30, time.Second*30)
+		before := lastSeen(t, s.ID)
+		_, err := rep.FindByID(ctx, s.ID, client)
+		require.NoError(t, err)
+		assert.Equal(t, before, lastSeen(t, s.ID))
+	})
+
+	t.Run("touched after touch interval", func(t *testing.T) {
+		s := create(t)
+		age(t, s.ID, touchInterval*2, touchInterval*2)
+		before := lastSeen(t, s.ID)
+		_, err := rep.FindByID(ctx, s.ID, client)
+		require.NoError(t, err)
+		assert.True(t, lastSeen(t, s.ID).After(before))
+	})
+
+	t.Run("check does not extend session", func(t *testing.T) {
+		s := create(t)
+		age(t, s.ID, touchInterval*2, touchInterval*2)
+		before := lastSeen(t, s.ID)
+		require.NoError(t, rep.Check(ctx, s.ID))
+		assert.Equal(t, before, lastSeen(t, s.ID))
+
+		// частые проверки, например из потока событий, не спасают сессию от таймаута бездействия
+		age(t, s.ID, time.Minute*11, time.Minute*11)
+		assert.ErrorIs(t, rep.Check(ctx, s.ID), session.ErrNoRows)
+	})
+
+	t.Run("touched on client change", func(t *testing.T) {
+		s := create(t)
+		age(t, s.ID, time.Second*30, time.Second*30)
+		before := lastSeen(t, s.ID)
+		moved := session.Client{IP: "192.0.2.2", UserAgent: "test"}
+		_, err := rep.FindByID(ctx, s.ID, moved)
+		require.NoError(t, err)
+		assert.True(t, lastSeen(t, s.ID).After(before))
+
+		got, err := rep.FindByID(ctx, s.ID, moved)
+		require.NoError(t, err)
+		assert.Equal(t, moved.IP, got.IP)
+	})
+}
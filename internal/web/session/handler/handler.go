@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/nickzhog/gophermart/internal/repositories"
+	"github.com/nickzhog/gophermart/internal/service/user"
+	"github.com/nickzhog/gophermart/internal/web/response"
+	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/pkg/logging"
+)
+
+type handler struct {
+	logger *logging.Logger
+	cookie session.CookieOptions
+	repositories.Repositories
+}
+
+func NewHandler(logger *logging.Logger, reps repositories.Repositories, cookie session.CookieOptions) *handler {
+	return &handler{
+		logger:       logger,
+		cookie:       cookie,
+		Repositories: reps,
+	}
+}
+
+func (h *handler) GetRouteGroup() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Post("/logout", h.logoutHandler)
+		r.Get("/sessions", h.sessionsHandler)
+		r.Delete("/sessions", h.deleteAllSessionsHandler)
+		r.Delete("/sessions/{id}", h.deleteSessionHandler)
+	}
+}
+
+// завершение текущей сессии
+func (h *handler) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	sID := session.GetSessionIDFromRequest(r)
+
+	if err := h.Session.Disable(r.Context(), sID); err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	session.ClearSessionCookie(w, h.cookie)
+
+	response.Text(h.logger, w, http.StatusOK, "logged out")
+}
+
+// список действующих сессий пользователя
+func (h *handler) sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	usrID := user.GetUserIDFromRequest(r)
+	sID := session.GetSessionIDFromRequest(r)
+
+	// ErrNotSupported даёт 501
+	sessions, err := h.Session.FindForUser(r.Context(), usrID)
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == sID
+	}
+
+	response.JSON(h.logger, w, r, http.StatusOK, sessions)
+}
+
+// завершение одной из сессий пользователя
+func (h *handler) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	usrID := user.GetUserIDFromRequest(r)
+
+	// ErrNoRows даёт 404, ErrNotSupported 501
+	err := h.Session.DisableByPublicID(r.Context(), usrID, chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+
+	response.Text(h.logger, w, http.StatusOK, "session closed")
+}
+
+// завершение всех сессий пользователя, включая текущую
+func (h *handler) deleteAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	usrID := user.GetUserIDFromRequest(r)
+
+	_, err := h.Session.DisableForUser(r.Context(), usrID, "")
+	if err != nil {
+		response.Error(h.logger, w, r, err)
+		return
+	}
+	session.ClearSessionCookie(w, h.cookie)
+
+	response.Text(h.logger, w, http.StatusOK, "all sessions closed")
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	"github.com/nickzhog/gophermart/internal/web/session"
+	mock_session "github.com/nickzhog/gophermart/internal/web/session/mocks"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	validUsrID = "ValidUsrID"
+	tokenUsrID = "TokenUsrID"
+
+	currentSessionID = "CurrentSessionID"
+	otherSessionID   = "OtherSessionID"
+	otherPublicID    = "OtherPublicID"
+)
+
+func prepareHandler(ctrl *gomock.Controller) *handler {
+	h := &handler{
+		logger: logging.GetLogger(),
+	}
+
+	sessionRep := mock_session.NewMockRepository(ctrl)
+	sessionRep.EXPECT().Disable(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	sessionRep.EXPECT().FindForUser(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID string) ([]session.Session, error) {
+			if usrID == tokenUsrID {
+				return nil, session.ErrNotSupported
+			}
+			return []session.Session{
+				{ID: currentSessionID, PublicID: "CurrentPublicID", UserID: usrID, CreateAt: time.Now(), LastSeenAt: time.Now()},
+				{ID: otherSessionID, PublicID: otherPublicID, UserID: usrID, CreateAt: time.Now(), LastSeenAt: time.Now(),
+					Client: session.Client{IP: "10.0.0.1", UserAgent: "curl/7.85.0"}},
+			}, nil
+		})
+	sessionRep.EXPECT().DisableByPublicID(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID, publicID string) error {
+			if usrID == tokenUsrID {
+				return session.ErrNotSupported
+			}
+			if publicID != otherPublicID {
+				return session.ErrNoRows
+			}
+			return nil
+		})
+	sessionRep.EXPECT().DisableForUser(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, usrID, keepID string) (int64, error) {
+			if usrID == tokenUsrID {
+				return 0, session.ErrNotSupported
+			}
+			return 2, nil
+		})
+	h.Repositories.Session = sessionRep
+
+	return h
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		usrID       string
+		wantStatus  int
+		wantCleared bool
+	}{
+		{
+			name:        "logout",
+			method:      http.MethodPost,
+			target:      "/logout",
+			usrID:       validUsrID,
+			wantStatus:  http.StatusOK,
+			wantCleared: true,
+		},
+		{
+			name:       "list sessions",
+			method:     http.MethodGet,
+			target:     "/sessions",
+			usrID:      validUsrID,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list sessions in token mode",
+			method:     http.MethodGet,
+			target:     "/sessions",
+			usrID:      tokenUsrID,
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "close other session",
+			method:     http.MethodDelete,
+			target:     "/sessions/" + otherPublicID,
+			usrID:      validUsrID,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "close unknown session",
+			method:     http.MethodDelete,
+			target:     "/sessions/unknown",
+			usrID:      validUsrID,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:        "log out everywhere",
+			method:      http.MethodDelete,
+			target:      "/sessions",
+			usrID:       validUsrID,
+			wantStatus:  http.StatusOK,
+			wantCleared: true,
+		},
+		{
+			name:       "log out everywhere in token mode",
+			method:     http.MethodDelete,
+			target:     "/sessions",
+			usrID:      tokenUsrID,
+			wantStatus: http.StatusNotImplemented,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			h := prepareHandler(ctrl)
+			r := chi.NewRouter()
+			r.Group(h.GetRouteGroup())
+
+			request := httptest.NewRequest(tt.method, tt.target, bytes.NewBuffer(nil))
+			request = session.PutSessionDataInRequest(request, currentSessionID, tt.usrID)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(tt.wantStatus, res.StatusCode)
+
+			cleared := false
+			for _, c := range res.Cookies() {
+				cleared = cleared || (c.Name == session.CookieKey && c.MaxAge < 0)
+			}
+			assert.Equal(tt.wantCleared, cleared)
+		})
+	}
+}
+
+func TestHandler_sessionsHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := prepareHandler(ctrl)
+
+	request := httptest.NewRequest(http.MethodGet, "/sessions", bytes.NewBuffer(nil))
+	request = session.PutSessionDataInRequest(request, currentSessionID, validUsrID)
+
+	w := httptest.NewRecorder()
+	http.HandlerFunc(h.sessionsHandler).ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+
+	var body bytes.Buffer
+	_, err := body.ReadFrom(res.Body)
+	assert.NoError(err)
+	assert.False(strings.Contains(body.String(), otherSessionID), "secret session id leaked")
+
+	var sessions []struct {
+		ID        string `json:"id"`
+		IP        string `json:"ip"`
+		UserAgent string `json:"user_agent"`
+		Current   bool   `json:"current"`
+	}
+	assert.NoError(json.Unmarshal(body.Bytes(), &sessions))
+	if assert.Len(sessions, 2) {
+		assert.True(sessions[0].Current)
+		assert.False(sessions[1].Current)
+		assert.Equal(otherPublicID, sessions[1].ID)
+		assert.Equal("10.0.0.1", sessions[1].IP)
+		assert.Equal("curl/7.85.0", sessions[1].UserAgent)
+	}
+}
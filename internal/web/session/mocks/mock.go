@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: internal/web/session/session.go
+// Source: repository.go
 
 // Package mock_session is a generated GoMock package.
 package mock_session
@@ -35,19 +35,33 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Check mocks base method.
+func (m *MockRepository) Check(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockRepositoryMockRecorder) Check(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockRepository)(nil).Check), ctx, id)
+}
+
 // Create mocks base method.
-func (m *MockRepository) Create(ctx context.Context, usrID string) (session.Session, error) {
+func (m *MockRepository) Create(ctx context.Context, usrID string, c session.Client) (session.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", ctx, usrID)
+	ret := m.ctrl.Call(m, "Create", ctx, usrID, c)
 	ret0, _ := ret[0].(session.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockRepositoryMockRecorder) Create(ctx, usrID interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Create(ctx, usrID, c interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), ctx, usrID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), ctx, usrID, c)
 }
 
 // Disable mocks base method.
@@ -64,17 +78,76 @@ func (mr *MockRepositoryMockRecorder) Disable(ctx, id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Disable", reflect.TypeOf((*MockRepository)(nil).Disable), ctx, id)
 }
 
+// DisableByPublicID mocks base method.
+func (m *MockRepository) DisableByPublicID(ctx context.Context, usrID, publicID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableByPublicID", ctx, usrID, publicID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableByPublicID indicates an expected call of DisableByPublicID.
+func (mr *MockRepositoryMockRecorder) DisableByPublicID(ctx, usrID, publicID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableByPublicID", reflect.TypeOf((*MockRepository)(nil).DisableByPublicID), ctx, usrID, publicID)
+}
+
+// DisableForUser mocks base method.
+func (m *MockRepository) DisableForUser(ctx context.Context, usrID, keepID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableForUser", ctx, usrID, keepID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DisableForUser indicates an expected call of DisableForUser.
+func (mr *MockRepositoryMockRecorder) DisableForUser(ctx, usrID, keepID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableForUser", reflect.TypeOf((*MockRepository)(nil).DisableForUser), ctx, usrID, keepID)
+}
+
 // FindByID mocks base method.
-func (m *MockRepository) FindByID(ctx context.Context, id string) (session.Session, error) {
+func (m *MockRepository) FindByID(ctx context.Context, id string, c session.Client) (session.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByID", ctx, id)
+	ret := m.ctrl.Call(m, "FindByID", ctx, id, c)
 	ret0, _ := ret[0].(session.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindByID indicates an expected call of FindByID.
-func (mr *MockRepositoryMockRecorder) FindByID(ctx, id interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FindByID(ctx, id, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockRepository)(nil).FindByID), ctx, id, c)
+}
+
+// FindForUser mocks base method.
+func (m *MockRepository) FindForUser(ctx context.Context, usrID string) ([]session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindForUser", ctx, usrID)
+	ret0, _ := ret[0].([]session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindForUser indicates an expected call of FindForUser.
+func (mr *MockRepositoryMockRecorder) FindForUser(ctx, usrID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindForUser", reflect.TypeOf((*MockRepository)(nil).FindForUser), ctx, usrID)
+}
+
+// PurgeExpired mocks base method.
+func (m *MockRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpired", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeExpired indicates an expected call of PurgeExpired.
+func (mr *MockRepositoryMockRecorder) PurgeExpired(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockRepository)(nil).FindByID), ctx, id)
-}
\ No newline at end of file
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpired", reflect.TypeOf((*MockRepository)(nil).PurgeExpired), ctx)
+}
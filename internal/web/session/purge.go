@@ -0,0 +1,35 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/logging"
+)
+
+// StartPurge периодически удаляет истёкшие сессии до отмены контекста
+func StartPurge(ctx context.Context, logger *logging.Logger, rep Repository, interval time.Duration) {
+	if interval <= 0 {
+		logger.Warnf("session purge interval %s is not positive, purge is disabled", interval)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := rep.PurgeExpired(ctx)
+			if err != nil {
+				logger.Errorf("cant purge expired sessions: %s", err.Error())
+				continue
+			}
+			if n > 0 {
+				logger.Tracef("purged %d expired sessions", n)
+			}
+		}
+	}
+}
@@ -3,7 +3,21 @@ package session
 import "context"
 
 type Repository interface {
-	Create(ctx context.Context, usrID string) (Session, error)
-	FindByID(ctx context.Context, id string) (Session, error)
+	Create(ctx context.Context, usrID string, c Client) (Session, error)
+	// FindByID возвращает действующую сессию, продлевает её и запоминает адрес и агент клиента
+	FindByID(ctx context.Context, id string, c Client) (Session, error)
+	// Check возвращает ErrNoRows, если сессия завершена или истекла. В отличие от FindByID
+	// сессия не продлевается, поэтому фоновые проверки не мешают таймауту бездействия
+	Check(ctx context.Context, id string) error
+	// FindForUser возвращает действующие сессии пользователя, новые первыми
+	FindForUser(ctx context.Context, usrID string) ([]Session, error)
 	Disable(ctx context.Context, id string) error
+	// DisableByPublicID завершает сессию пользователя по публичному идентификатору,
+	// возвращает ErrNoRows, если такой действующей сессии у пользователя нет
+	DisableByPublicID(ctx context.Context, usrID, publicID string) error
+	// DisableForUser завершает сессии пользователя, кроме keepID, возвращает их количество.
+	// Пустой keepID завершает все сессии
+	DisableForUser(ctx context.Context, usrID, keepID string) (int64, error)
+	// PurgeExpired удаляет истёкшие и отключённые сессии, возвращает их количество
+	PurgeExpired(ctx context.Context) (int64, error)
 }
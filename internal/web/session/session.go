@@ -2,21 +2,63 @@ package session
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/nickzhog/gophermart/internal/service/user"
+	"github.com/nickzhog/gophermart/pkg/apperr"
 )
 
+// Session ID является секретом клиента, наружу отдаётся только PublicID
 type Session struct {
-	ID       string
-	UserID   string
-	CreateAt time.Time
-	IsActive bool
+	ID         string    `json:"-"`
+	PublicID   string    `json:"id"`
+	UserID     string    `json:"-"`
+	CreateAt   time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	IsActive   bool      `json:"-"`
+	Client
+	Current bool `json:"current"`
+}
+
+// Client адрес и агент, с которых последний раз использовалась сессия
+type Client struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// maxUserAgentLen ограничивает сохраняемый заголовок User-Agent
+const maxUserAgentLen = 512
+
+func ClientFromRequest(r *http.Request) Client {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLen {
+		ua = ua[:maxUserAgentLen]
+	}
+	return Client{IP: ip, UserAgent: ua}
+}
+
+// CookieOptions атрибуты cookie сессии
+type CookieOptions struct {
+	Secure bool
+	MaxAge time.Duration
 }
 
 type SessionID string
 
+var (
+	ErrBadAuthorization = apperr.New(apperr.ErrUnauthorized, "bad authorization header")
+	ErrUnauthorized     = apperr.New(apperr.ErrUnauthorized, "unauthorized")
+	ErrNoRows           = apperr.New(apperr.ErrNotFound, "session not found")
+	ErrNotSupported     = apperr.New(apperr.ErrNotImplemented, "not supported by session storage")
+)
+
 const (
 	CookieKey            = "session"
 	ContextKey SessionID = "session"
@@ -30,12 +72,45 @@ func GetSessionFromCookie(r *http.Request) (string, error) {
 	return s.Value, nil
 }
 
-func PutSessionIDInCookie(w http.ResponseWriter, sID string) {
+// GetSessionFromRequest берёт сессию из заголовка Authorization: Bearer, иначе из cookie
+func GetSessionFromRequest(r *http.Request) (string, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, sID, ok := strings.Cut(auth, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(sID) == "" {
+			return "", ErrBadAuthorization
+		}
+		return strings.TrimSpace(sID), nil
+	}
+	return GetSessionFromCookie(r)
+}
+
+func PutSessionIDInCookie(w http.ResponseWriter, sID string, opts CookieOptions) {
 	cookie := http.Cookie{
 		Name:     CookieKey,
 		Value:    sID,
 		Path:     "/",
 		HttpOnly: true,
+		Secure:   opts.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if opts.MaxAge > 0 {
+		cookie.MaxAge = int(opts.MaxAge.Seconds())
+		cookie.Expires = time.Now().Add(opts.MaxAge).UTC()
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// ClearSessionCookie удаляет cookie сессии у клиента
+func ClearSessionCookie(w http.ResponseWriter, opts CookieOptions) {
+	cookie := http.Cookie{
+		Name:     CookieKey,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   opts.Secure,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
 	}
 	http.SetCookie(w, &cookie)
 }
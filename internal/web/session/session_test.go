@@ -0,0 +1,77 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutSessionIDInCookie(t *testing.T) {
+	assert := assert.New(t)
+
+	w := httptest.NewRecorder()
+	PutSessionIDInCookie(w, "sid", CookieOptions{Secure: true, MaxAge: time.Hour})
+
+	cookies := w.Result().Cookies()
+	if assert.Len(cookies, 1) {
+		c := cookies[0]
+		assert.Equal(CookieKey, c.Name)
+		assert.Equal("sid", c.Value)
+		assert.True(c.HttpOnly)
+		assert.True(c.Secure)
+		assert.Equal(http.SameSiteLaxMode, c.SameSite)
+		assert.Equal(3600, c.MaxAge)
+		assert.WithinDuration(time.Now().Add(time.Hour), c.Expires, time.Minute)
+	}
+}
+
+func TestGetSessionFromRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		cookie  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "bearer",
+			header: "Bearer token",
+			cookie: "cookie",
+			want:   "token",
+		},
+		{
+			name:   "cookie",
+			cookie: "cookie",
+			want:   "cookie",
+		},
+		{
+			name:    "other scheme",
+			header:  "Basic dXNlcjpwYXNz",
+			wantErr: true,
+		},
+		{
+			name:    "nothing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CookieKey, Value: tt.cookie})
+			}
+
+			got, err := GetSessionFromRequest(r)
+			assert.Equal(tt.wantErr, err != nil)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cachedRevocations запоминает результат проверки отзыва токена на ttl, чтобы не
+// обращаться к БД на каждый запрос. Отзыв через этот экземпляр виден сразу,
+// отзыв через другие экземпляры сервиса - не позже чем через ttl
+type cachedRevocations struct {
+	Revocations
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	checks    map[string]check
+	lastSweep time.Time
+}
+
+// check результат проверки токена с идентификатором jti
+type check struct {
+	usrID     string
+	revoked   bool
+	expiresAt time.Time
+}
+
+func (c *cachedRevocations) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := c.Revocations.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.checks, jti)
+	return nil
+}
+
+func (c *cachedRevocations) RevokeForUser(ctx context.Context, usrID, keepJTI string) error {
+	if err := c.Revocations.RevokeForUser(ctx, usrID, keepJTI); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for jti, ch := range c.checks {
+		if ch.usrID == usrID {
+			delete(c.checks, jti)
+		}
+	}
+	return nil
+}
+
+func (c *cachedRevocations) IsRevoked(ctx context.Context, usrID, jti string, epoch int64) (bool, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	ch, ok := c.checks[jti]
+	c.mu.Unlock()
+	if ok && ch.usrID == usrID && now.Before(ch.expiresAt) {
+		return ch.revoked, nil
+	}
+
+	revoked, err := c.Revocations.IsRevoked(ctx, usrID, jti, epoch)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweep(now)
+	c.checks[jti] = check{usrID: usrID, revoked: revoked, expiresAt: now.Add(c.ttl)}
+	return revoked, nil
+}
+
+// sweep удаляет устаревшие проверки не чаще раза в ttl, чтобы кэш не рос
+// за счёт токенов, которые больше не предъявляются
+func (c *cachedRevocations) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	for jti, ch := range c.checks {
+		if !now.Before(ch.expiresAt) {
+			delete(c.checks, jti)
+		}
+	}
+	c.lastSweep = now
+}
+
+// NewCachedRevocations кэширует проверки отзыва токенов на ttl.
+// Без положительного ttl каждая проверка обращается к rv
+func NewCachedRevocations(rv Revocations, ttl time.Duration) Revocations {
+	if ttl <= 0 {
+		return rv
+	}
+
+	return &cachedRevocations{
+		Revocations: rv,
+		ttl:         ttl,
+		now:         time.Now,
+		checks:      make(map[string]check),
+	}
+}
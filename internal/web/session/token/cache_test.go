@@ -0,0 +1,59 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRevocations считает обращения к хранилищу отзывов
+type countingRevocations struct {
+	*revocations
+	checks int
+}
+
+func (rv *countingRevocations) IsRevoked(ctx context.Context, usrID, jti string, epoch int64) (bool, error) {
+	rv.checks++
+	return rv.revocations.IsRevoked(ctx, usrID, jti, epoch)
+}
+
+func TestCachedRevocations(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	stored := &countingRevocations{revocations: newRevocations()}
+	c := NewCachedRevocations(stored, time.Second*5).(*cachedRevocations)
+	c.now = func() time.Time { return now }
+
+	isRevoked := func(jti string) bool {
+		revoked, err := c.IsRevoked(ctx, "user", jti, 0)
+		require.NoError(t, err)
+		return revoked
+	}
+
+	assert.False(isRevoked("a"))
+	assert.False(isRevoked("a"))
+	assert.Equal(1, stored.checks, "second check is cached")
+
+	// отзыв через другой экземпляр виден после истечения кэша
+	require.NoError(t, stored.Revoke(ctx, "a", now.Add(time.Hour)))
+	assert.False(isRevoked("a"))
+	now = now.Add(time.Second * 5)
+	assert.True(isRevoked("a"))
+	assert.Equal(2, stored.checks)
+
+	// отзыв через этот экземпляр виден сразу
+	assert.False(isRevoked("b"))
+	require.NoError(t, c.Revoke(ctx, "b", now.Add(time.Hour)))
+	assert.True(isRevoked("b"))
+
+	assert.False(isRevoked("c"))
+	require.NoError(t, c.RevokeForUser(ctx, "user", ""))
+	assert.True(isRevoked("c"))
+
+	assert.Same(stored, NewCachedRevocations(stored, 0), "zero ttl disables cache")
+}
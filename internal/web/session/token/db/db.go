@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/nickzhog/gophermart/internal/web/session/token"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/nickzhog/gophermart/pkg/postgres"
+)
+
+type repository struct {
+	client postgres.Client
+	logger *logging.Logger
+}
+
+func (r *repository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	q := `
+		INSERT INTO public.revoked_tokens
+			(jti, expires_at)
+		VALUES
+			($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	_, err := r.client.Exec(ctx, q, jti, expiresAt)
+	if err != nil {
+		r.logger.Error(err)
+	}
+	return err
+}
+
+func (r *repository) RevokeForUser(ctx context.Context, usrID, keepJTI string) error {
+	q := `
+		INSERT INTO public.session_epochs
+			(user_id, epoch, kept_jti)
+		VALUES
+			($1, 1, NULLIF($2, ''))
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+			epoch = session_epochs.epoch + 1,
+			kept_jti = EXCLUDED.kept_jti
+	`
+	_, err := r.client.Exec(ctx, q, usrID, keepJTI)
+	return err
+}
+
+func (r *repository) Epoch(ctx context.Context, usrID string) (int64, error) {
+	q := `
+		SELECT COALESCE((
+			SELECT epoch FROM public.session_epochs WHERE user_id = $1
+		), 0)
+	`
+	var epoch int64
+	err := r.client.QueryRow(ctx, q, usrID).Scan(&epoch)
+	return epoch, err
+}
+
+func (r *repository) IsRevoked(ctx context.Context, usrID, jti string, epoch int64) (bool, error) {
+	q := `
+		SELECT EXISTS (
+			SELECT 1 FROM public.revoked_tokens WHERE jti = $2
+		) OR EXISTS (
+			SELECT 1 FROM public.session_epochs
+			WHERE
+				user_id = $1
+				AND epoch > $3
+				AND kept_jti IS DISTINCT FROM $2
+		)
+	`
+	var revoked bool
+	err := r.client.QueryRow(ctx, q, usrID, jti, epoch).Scan(&revoked)
+	if err != nil {
+		// подпись проверена, но субъект не UUID: токен выдан не этим сервисом
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == postgres.InvalidTextRepresentation {
+			return true, nil
+		}
+	}
+	return revoked, err
+}
+
+func (r *repository) PurgeExpired(ctx context.Context) (int64, error) {
+	q := `
+		DELETE FROM 
+			public.revoked_tokens 
+		WHERE 
+			expires_at <= CURRENT_TIMESTAMP
+	`
+	tag, err := r.client.Exec(ctx, q)
+	if err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// NewRepository хранит отозванные токены сессий в БД
+func NewRepository(client postgres.Client, logger *logging.Logger) token.Revocations {
+
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
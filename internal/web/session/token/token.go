@@ -0,0 +1,336 @@
+package token
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/pkg/logging"
+)
+
+const (
+	algorithm = "HS256"
+
+	// MinSecretLen минимальная длина секрета для HMAC-SHA256
+	MinSecretLen = 32
+
+	// jtiLen длина идентификатора токена в байтах
+	jtiLen = 16
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid session token")
+	ErrExpiredToken = errors.New("session token expired")
+	ErrBadKeys      = errors.New("bad session keys")
+	ErrRevokedToken = errors.New("session token revoked")
+)
+
+// Revocations хранит идентификаторы (jti) отозванных токенов до истечения их срока
+// и эпохи токенов пользователей для отзыва всех токенов разом
+type Revocations interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeForUser увеличивает эпоху пользователя, отзывая все его токены, кроме keepJTI
+	RevokeForUser(ctx context.Context, usrID, keepJTI string) error
+	// Epoch возвращает текущую эпоху пользователя для новых токенов
+	Epoch(ctx context.Context, usrID string) (int64, error)
+	// IsRevoked проверяет, отозван ли токен отдельно или сменой эпохи пользователя
+	IsRevoked(ctx context.Context, usrID, jti string, epoch int64) (bool, error)
+	// PurgeExpired удаляет записи о токенах, срок которых уже истёк
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// Key ключ подписи токенов, ID попадает в заголовок токена (kid)
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKeys разбирает список ключей вида "kid1:secret1,kid2:secret2".
+// Первым ключом подписываются новые токены, остальные только проверяются,
+// что позволяет менять ключ без разлогинивания пользователей.
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+	seen := make(map[string]bool)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(v, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("%w: key must be kid:secret", ErrBadKeys)
+		}
+		if len(secret) < MinSecretLen {
+			return nil, fmt.Errorf("%w: secret of key %q is shorter than %d bytes", ErrBadKeys, id, MinSecretLen)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrBadKeys, id)
+		}
+		seen[id] = true
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	if len(keys) < 1 {
+		return nil, fmt.Errorf("%w: no keys", ErrBadKeys)
+	}
+	return keys, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Subject  string `json:"sub"`
+	ID       string `json:"jti"`
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+	// Epoch эпоха пользователя на момент выдачи токена
+	Epoch int64 `json:"ep,omitempty"`
+}
+
+// repository выдаёт подписанные JWT вместо хранения сессий в БД.
+// В БД хранятся только отозванные токены, таймаут бездействия не применяется.
+// Отзыв проверяется на каждый запрос, поэтому Revocations стоит оборачивать в NewCachedRevocations
+type repository struct {
+	keys    []Key
+	ttl     time.Duration
+	revoked Revocations
+	now     func() time.Time
+	logger  *logging.Logger
+}
+
+func (r *repository) Create(ctx context.Context, usrID string, cl session.Client) (session.Session, error) {
+	jti := make([]byte, jtiLen)
+	if _, err := rand.Read(jti); err != nil {
+		return session.Session{}, err
+	}
+
+	epoch, err := r.revoked.Epoch(ctx, usrID)
+	if err != nil {
+		r.logger.Error(err)
+		return session.Session{}, err
+	}
+
+	now := r.now()
+	c := claims{
+		Subject:  usrID,
+		ID:       hex.EncodeToString(jti),
+		IssuedAt: now.Unix(),
+		Expires:  now.Add(r.ttl).Unix(),
+		Epoch:    epoch,
+	}
+
+	t, err := sign(r.keys[0], c)
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	return session.Session{
+		ID:         t,
+		PublicID:   c.ID,
+		UserID:     usrID,
+		CreateAt:   time.Unix(c.IssuedAt, 0),
+		LastSeenAt: time.Unix(c.IssuedAt, 0),
+		IsActive:   true,
+		Client:     cl,
+	}, nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id string, cl session.Client) (session.Session, error) {
+	c, err := r.check(ctx, id)
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	return session.Session{
+		ID:         id,
+		PublicID:   c.ID,
+		UserID:     c.Subject,
+		CreateAt:   time.Unix(c.IssuedAt, 0),
+		LastSeenAt: r.now(),
+		IsActive:   true,
+		Client:     cl,
+	}, nil
+}
+
+// Check проверяет подпись, срок и отзыв токена. Токены не продлеваются,
+// поэтому проверка не отличается от FindByID
+func (r *repository) Check(ctx context.Context, id string) error {
+	_, err := r.check(ctx, id)
+	return err
+}
+
+func (r *repository) check(ctx context.Context, id string) (claims, error) {
+	c, err := r.verify(id)
+	if err != nil {
+		r.logger.Tracef("session token rejected: %s", err.Error())
+		return claims{}, err
+	}
+	revoked, err := r.revoked.IsRevoked(ctx, c.Subject, c.ID, c.Epoch)
+	if err != nil {
+		r.logger.Error(err)
+		return claims{}, err
+	}
+	if revoked {
+		return claims{}, ErrRevokedToken
+	}
+	return c, nil
+}
+
+// FindForUser не поддерживается: выданные токены нигде не хранятся
+func (r *repository) FindForUser(ctx context.Context, usrID string) ([]session.Session, error) {
+	return nil, session.ErrNotSupported
+}
+
+// Disable отзывает токен, запись об отзыве хранится до истечения его срока.
+// Недействительный или истёкший токен и так не принимается, отзывать его не нужно
+func (r *repository) Disable(ctx context.Context, id string) error {
+	c, err := r.verify(id)
+	if err != nil {
+		return nil
+	}
+	return r.revoked.Revoke(ctx, c.ID, time.Unix(c.Expires, 0))
+}
+
+// DisableByPublicID отзывает токен по его jti, который и служит публичным идентификатором.
+// Выданные токены не хранятся, поэтому проверяется только формат jti, а запись
+// об отзыве хранится весь срок жизни токена, отсчитанный от текущего момента
+func (r *repository) DisableByPublicID(ctx context.Context, usrID, publicID string) error {
+	if jti, err := hex.DecodeString(publicID); err != nil || len(jti) != jtiLen {
+		return session.ErrNoRows
+	}
+	if err := r.revoked.Revoke(ctx, publicID, r.now().Add(r.ttl)); err != nil {
+		r.logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+// DisableForUser отзывает все токены пользователя, кроме keepID. Число отозванных
+// токенов неизвестно, поэтому всегда возвращается 0
+func (r *repository) DisableForUser(ctx context.Context, usrID, keepID string) (int64, error) {
+	var keepJTI string
+	if keepID != "" {
+		c, err := r.verify(keepID)
+		if err == nil && c.Subject == usrID {
+			keepJTI = c.ID
+		}
+	}
+	if err := r.revoked.RevokeForUser(ctx, usrID, keepJTI); err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+	return 0, nil
+}
+
+// PurgeExpired удаляет записи об отозванных токенах, которые уже истекли
+func (r *repository) PurgeExpired(ctx context.Context) (int64, error) {
+	return r.revoked.PurgeExpired(ctx)
+}
+
+func sign(k Key, c claims) (string, error) {
+	h, err := json.Marshal(header{Alg: algorithm, Typ: "JWT", Kid: k.ID})
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encode(h) + "." + encode(p)
+	return unsigned + "." + encode(mac(k.Secret, unsigned)), nil
+}
+
+func (r *repository) verify(t string) (claims, error) {
+	parts := strings.Split(t, ".")
+	if len(parts) != 3 {
+		return claims{}, ErrInvalidToken
+	}
+
+	var h header
+	if err := decode(parts[0], &h); err != nil {
+		return claims{}, err
+	}
+	if h.Alg != algorithm {
+		return claims{}, fmt.Errorf("%w: unexpected alg %q", ErrInvalidToken, h.Alg)
+	}
+
+	key, ok := r.findKey(h.Kid)
+	if !ok {
+		return claims{}, fmt.Errorf("%w: unknown kid %q", ErrInvalidToken, h.Kid)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims{}, ErrInvalidToken
+	}
+	if !hmac.Equal(sig, mac(key.Secret, parts[0]+"."+parts[1])) {
+		return claims{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var c claims
+	if err := decode(parts[1], &c); err != nil {
+		return claims{}, err
+	}
+	if c.Subject == "" {
+		return claims{}, fmt.Errorf("%w: empty subject", ErrInvalidToken)
+	}
+	if !r.now().Before(time.Unix(c.Expires, 0)) {
+		return claims{}, ErrExpiredToken
+	}
+
+	return c, nil
+}
+
+func (r *repository) findKey(id string) (Key, bool) {
+	for _, k := range r.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func mac(secret []byte, data string) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(s string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func NewRepository(keys []Key, ttl time.Duration, revoked Revocations, logger *logging.Logger) session.Repository {
+
+	return &repository{
+		keys:    keys,
+		ttl:     ttl,
+		revoked: revoked,
+		now:     time.Now,
+		logger:  logger,
+	}
+}
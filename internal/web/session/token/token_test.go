@@ -0,0 +1,257 @@
+package token
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/web/session"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	secret1 = "0123456789abcdef0123456789abcdef"
+	secret2 = "fedcba9876543210fedcba9876543210"
+)
+
+// revocations хранит отозванные токены и эпохи пользователей в памяти
+type revocations struct {
+	tokens map[string]time.Time
+	epochs map[string]int64
+	kept   map[string]string
+}
+
+func newRevocations() *revocations {
+	return &revocations{
+		tokens: make(map[string]time.Time),
+		epochs: make(map[string]int64),
+		kept:   make(map[string]string),
+	}
+}
+
+func (rv *revocations) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	rv.tokens[jti] = expiresAt
+	return nil
+}
+
+func (rv *revocations) RevokeForUser(ctx context.Context, usrID, keepJTI string) error {
+	rv.epochs[usrID]++
+	rv.kept[usrID] = keepJTI
+	return nil
+}
+
+func (rv *revocations) Epoch(ctx context.Context, usrID string) (int64, error) {
+	return rv.epochs[usrID], nil
+}
+
+func (rv *revocations) IsRevoked(ctx context.Context, usrID, jti string, epoch int64) (bool, error) {
+	if _, ok := rv.tokens[jti]; ok {
+		return true, nil
+	}
+	return epoch < rv.epochs[usrID] && jti != rv.kept[usrID], nil
+}
+
+func (rv *revocations) PurgeExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func newRepository(t *testing.T, keys string, now time.Time) *repository {
+	return newRepositoryWith(t, keys, now, newRevocations())
+}
+
+func newRepositoryWith(t *testing.T, keys string, now time.Time, rv Revocations) *repository {
+	k, err := ParseKeys(keys)
+	require.NoError(t, err)
+	r := NewRepository(k, time.Hour, rv, logging.GetLogger()).(*repository)
+	r.now = func() time.Time { return now }
+	return r
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name:    "rotation",
+			keys:    "k2:" + secret2 + ", k1:" + secret1,
+			wantIDs: []string{"k2", "k1"},
+		},
+		{
+			name:    "empty",
+			keys:    "",
+			wantErr: true,
+		},
+		{
+			name:    "short secret",
+			keys:    "k1:secret",
+			wantErr: true,
+		},
+		{
+			name:    "without kid",
+			keys:    secret1,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate kid",
+			keys:    "k1:" + secret1 + ",k1:" + secret2,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			keys, err := ParseKeys(tt.keys)
+			assert.Equal(tt.wantErr, err != nil)
+
+			var ids []string
+			for _, k := range keys {
+				ids = append(ids, k.ID)
+			}
+			assert.Equal(tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	old := newRepository(t, "k1:"+secret1, now)
+
+	s, err := old.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+
+	t.Run("valid token", func(t *testing.T) {
+		got, err := old.FindByID(ctx, s.ID, session.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, "user", got.UserID)
+	})
+
+	t.Run("rotated key still verifies old tokens", func(t *testing.T) {
+		rotated := newRepository(t, "k2:"+secret2+",k1:"+secret1, now)
+		got, err := rotated.FindByID(ctx, s.ID, session.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, "user", got.UserID)
+
+		fresh, err := rotated.Create(ctx, "user", session.Client{})
+		require.NoError(t, err)
+		_, err = old.FindByID(ctx, fresh.ID, session.Client{})
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("removed key", func(t *testing.T) {
+		_, err := newRepository(t, "k2:"+secret2, now).FindByID(ctx, s.ID, session.Client{})
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := newRepository(t, "k1:"+secret1, now.Add(time.Hour)).FindByID(ctx, s.ID, session.Client{})
+		assert.ErrorIs(t, err, ErrExpiredToken)
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		parts := strings.Split(s.ID, ".")
+		forged, err := sign(Key{ID: "k1", Secret: []byte(secret2)}, claims{Subject: "admin", Expires: now.Add(time.Hour).Unix()})
+		require.NoError(t, err)
+		parts[1] = strings.Split(forged, ".")[1]
+
+		_, err = old.FindByID(ctx, strings.Join(parts, "."), session.Client{})
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		_, err := old.FindByID(ctx, "not-a-token", session.Client{})
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestRepository_Disable(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	rv := newRevocations()
+	r := newRepositoryWith(t, "k1:"+secret1, now, rv)
+
+	s, err := r.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+	other, err := r.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+
+	require.NoError(t, r.Disable(ctx, s.ID))
+	assert.Equal(t, now.Add(time.Hour).Unix(), rv.tokens[s.PublicID].Unix())
+
+	_, err = r.FindByID(ctx, s.ID, session.Client{})
+	assert.ErrorIs(t, err, ErrRevokedToken)
+	assert.ErrorIs(t, r.Check(ctx, s.ID), ErrRevokedToken)
+	assert.NoError(t, r.Check(ctx, other.ID))
+
+	_, err = r.FindByID(ctx, other.ID, session.Client{})
+	assert.NoError(t, err, "other tokens of the user stay valid")
+
+	assert.NoError(t, r.Disable(ctx, "not-a-token"))
+	assert.Len(t, rv.tokens, 1)
+}
+
+func TestRepository_DisableByPublicID(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	rv := newRevocations()
+	r := newRepositoryWith(t, "k1:"+secret1, now, rv)
+
+	s, err := r.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+	other, err := r.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+
+	require.NoError(t, r.DisableByPublicID(ctx, "user", s.PublicID))
+	assert.Equal(t, now.Add(time.Hour).Unix(), rv.tokens[s.PublicID].Unix())
+
+	_, err = r.FindByID(ctx, s.ID, session.Client{})
+	assert.ErrorIs(t, err, ErrRevokedToken)
+	_, err = r.FindByID(ctx, other.ID, session.Client{})
+	assert.NoError(t, err, "other tokens of the user stay valid")
+
+	for _, id := range []string{"", "not-a-jti", s.PublicID[:8]} {
+		assert.ErrorIs(t, r.DisableByPublicID(ctx, "user", id), session.ErrNoRows, id)
+	}
+	assert.Len(t, rv.tokens, 1)
+}
+
+func TestRepository_DisableForUser(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	r := newRepository(t, "k1:"+secret1, now)
+
+	current, err := r.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+	other, err := r.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+	stranger, err := r.Create(ctx, "stranger", session.Client{})
+	require.NoError(t, err)
+
+	_, err = r.DisableForUser(ctx, "user", current.ID)
+	require.NoError(t, err)
+
+	_, err = r.FindByID(ctx, current.ID, session.Client{})
+	assert.NoError(t, err, "kept token stays valid")
+	_, err = r.FindByID(ctx, other.ID, session.Client{})
+	assert.ErrorIs(t, err, ErrRevokedToken)
+	_, err = r.FindByID(ctx, stranger.ID, session.Client{})
+	assert.NoError(t, err, "tokens of other users stay valid")
+
+	fresh, err := r.Create(ctx, "user", session.Client{})
+	require.NoError(t, err)
+	_, err = r.FindByID(ctx, fresh.ID, session.Client{})
+	assert.NoError(t, err, "tokens issued after revocation are valid")
+
+	_, err = r.DisableForUser(ctx, "user", "")
+	require.NoError(t, err)
+	for _, s := range []session.Session{current, fresh} {
+		_, err = r.FindByID(ctx, s.ID, session.Client{})
+		assert.ErrorIs(t, err, ErrRevokedToken)
+	}
+}
@@ -0,0 +1,160 @@
+package webhooksender
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	"github.com/nickzhog/gophermart/pkg/logging"
+)
+
+type Sender interface {
+	StartDelivery(ctx context.Context) error
+}
+
+type sender struct {
+	Logger     *logging.Logger
+	Cfg        *config.Config
+	WebhookRep webhook.Repository
+	HTTPClient *http.Client
+}
+
+func NewSender(logger *logging.Logger, cfg *config.Config, webhookRep webhook.Repository) Sender {
+	return &sender{
+		Logger:     logger,
+		Cfg:        cfg,
+		WebhookRep: webhookRep,
+		HTTPClient: &http.Client{
+			Timeout: cfg.Settings.WebhookRequestTimeout,
+		},
+	}
+}
+
+// maxErrorBody - сколько байт ответа получателя сохраняется в описании ошибки
+const maxErrorBody = 512
+
+func (s *sender) workers() int {
+	if s.Cfg.Settings.WebhookWorkers < 1 {
+		return 1
+	}
+	return s.Cfg.Settings.WebhookWorkers
+}
+
+// lease - время, на которое доставки захватываются экземпляром сервиса для отправки.
+// Последняя доставка пачки ждёт, пока воркеры отправят предыдущие, поэтому захват
+// покрывает все раунды отправки пачки с таймаутом запроса и ещё один раунд про запас
+func (s *sender) lease() time.Duration {
+	workers := s.workers()
+	rounds := (s.Cfg.Settings.WebhookBatchSize + workers - 1) / workers
+	return time.Duration(rounds+1) * s.Cfg.Settings.WebhookRequestTimeout
+}
+
+func (s *sender) StartDelivery(ctx context.Context) error {
+	workers := s.workers()
+
+	jobs := make(chan webhook.Delivery)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				s.deliver(ctx, d)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(s.Cfg.Settings.WebhookScanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.scan(ctx, jobs)
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			s.Logger.Trace("webhooks delivery exited properly")
+			return nil
+		}
+	}
+}
+
+// scan раздаёт воркерам доставки, время попытки которых наступило
+func (s *sender) scan(ctx context.Context, jobs chan<- webhook.Delivery) {
+	deliveries, err := s.WebhookRep.FindDue(ctx, s.Cfg.Settings.WebhookBatchSize, s.lease())
+	if err != nil {
+		s.Logger.Error(err)
+		return
+	}
+	for _, d := range deliveries {
+		select {
+		case jobs <- d:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *sender) deliver(ctx context.Context, d webhook.Delivery) {
+	statusCode, err := s.send(ctx, d)
+	if ctx.Err() != nil {
+		// доставка будет повторена после истечения захвата
+		return
+	}
+
+	if err != nil {
+		d.Fail(statusCode, err.Error(), s.Cfg.Settings.WebhookMaxAttempts,
+			s.Cfg.Settings.WebhookBackoffBase, s.Cfg.Settings.WebhookBackoffMax)
+		if d.Status == webhook.DeliveryDead {
+			s.Logger.Warnf("webhook delivery %d to %s is dead after %d attempts: %s", d.ID, d.URL, d.Attempts, err.Error())
+		} else {
+			s.Logger.Tracef("webhook delivery %d to %s failed: %s", d.ID, d.URL, err.Error())
+		}
+	} else {
+		d.Succeed(statusCode)
+	}
+
+	if err = s.WebhookRep.SaveAttempt(ctx, &d); err != nil {
+		s.Logger.Errorf("cant save webhook delivery %d: %s", d.ID, err.Error())
+	}
+}
+
+// send отправляет доставку и возвращает код ответа получателя.
+// Успехом считается только ответ 2xx
+func (s *sender) send(ctx context.Context, d webhook.Delivery) (int, error) {
+	body, err := d.Body()
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhook.HeaderEvent, d.Event)
+	request.Header.Set(webhook.HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	request.Header.Set(webhook.HeaderSignature, webhook.Sign(d.Secret, time.Now(), body))
+
+	res, err := s.HTTPClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	answer, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	// остаток тела дочитывается, чтобы соединение вернулось в пул
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d: %s", res.StatusCode, bytes.TrimSpace(answer))
+	}
+	return res.StatusCode, nil
+}
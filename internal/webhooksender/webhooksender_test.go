@@ -0,0 +1,181 @@
+package webhooksender
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nickzhog/gophermart/internal/config"
+	"github.com/nickzhog/gophermart/internal/service/webhook"
+	mock_webhook "github.com/nickzhog/gophermart/internal/service/webhook/mocks"
+	"github.com/nickzhog/gophermart/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+const secret = "secret"
+
+func Test_sender_deliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		attempts     int
+		wantStatus   string
+		wantAttempts int
+		wantError    bool
+	}{
+		{
+			name:         "delivered",
+			status:       http.StatusNoContent,
+			wantStatus:   webhook.DeliveryDelivered,
+			wantAttempts: 1,
+		},
+		{
+			name:         "receiver error",
+			status:       http.StatusInternalServerError,
+			wantStatus:   webhook.DeliveryPending,
+			wantAttempts: 1,
+			wantError:    true,
+		},
+		{
+			name:         "redirect is not success",
+			status:       http.StatusNotModified,
+			wantStatus:   webhook.DeliveryPending,
+			wantAttempts: 1,
+			wantError:    true,
+		},
+		{
+			name:         "last attempt",
+			status:       http.StatusBadGateway,
+			attempts:     2,
+			wantStatus:   webhook.DeliveryDead,
+			wantAttempts: 3,
+			wantError:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var got *http.Request
+			var body []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			d := webhook.Delivery{
+				ID:        7,
+				Event:     webhook.EventOrderAccrued,
+				Payload:   json.RawMessage(`{"number":"2377225624"}`),
+				Status:    webhook.DeliveryPending,
+				Attempts:  tt.attempts,
+				CreatedAt: time.Now(),
+				URL:       receiver.URL,
+				Secret:    secret,
+			}
+
+			var saved webhook.Delivery
+			webhookRep := mock_webhook.NewMockRepository(ctrl)
+			webhookRep.EXPECT().SaveAttempt(gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(func(ctx context.Context, d *webhook.Delivery) error {
+					saved = *d
+					return nil
+				})
+
+			s := newTestSender(webhookRep)
+			s.deliver(context.Background(), d)
+
+			if assert.NotNil(got) {
+				assert.Equal(http.MethodPost, got.Method)
+				assert.Equal("application/json", got.Header.Get("Content-Type"))
+				assert.Equal(webhook.EventOrderAccrued, got.Header.Get(webhook.HeaderEvent))
+				assert.Equal("7", got.Header.Get(webhook.HeaderDelivery))
+				assert.NoError(webhook.Verify(secret, got.Header.Get(webhook.HeaderSignature), body, time.Now(), time.Minute))
+				assert.Contains(string(body), `"data":{"number":"2377225624"}`)
+			}
+
+			assert.Equal(tt.wantStatus, saved.Status)
+			assert.Equal(tt.wantAttempts, saved.Attempts)
+			assert.Equal(tt.status, saved.LastStatusCode)
+			assert.Equal(tt.wantError, saved.LastError != "")
+			if tt.wantStatus == webhook.DeliveryPending {
+				assert.Positive(saved.RetryIn)
+			}
+		})
+	}
+}
+
+func Test_sender_deliver_unreachable(t *testing.T) {
+	assert := assert.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	receiver.Close()
+
+	var saved webhook.Delivery
+	webhookRep := mock_webhook.NewMockRepository(ctrl)
+	webhookRep.EXPECT().SaveAttempt(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(ctx context.Context, d *webhook.Delivery) error {
+			saved = *d
+			return nil
+		})
+
+	s := newTestSender(webhookRep)
+	s.deliver(context.Background(), webhook.Delivery{ID: 1, Status: webhook.DeliveryPending, URL: receiver.URL})
+
+	assert.Equal(webhook.DeliveryPending, saved.Status)
+	assert.Equal(1, saved.Attempts)
+	assert.Zero(saved.LastStatusCode)
+	assert.NotEmpty(saved.LastError)
+}
+
+func Test_sender_deliver_canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	defer receiver.Close()
+
+	// при остановке попытка не сохраняется, доставку повторит следующий захват
+	webhookRep := mock_webhook.NewMockRepository(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := newTestSender(webhookRep)
+	s.deliver(ctx, webhook.Delivery{ID: 1, Status: webhook.DeliveryPending, URL: receiver.URL})
+}
+
+func newTestSender(webhookRep webhook.Repository) *sender {
+	cfg := &config.Config{}
+	cfg.Settings.WebhookRequestTimeout = time.Second
+	cfg.Settings.WebhookMaxAttempts = 3
+	cfg.Settings.WebhookBackoffBase = time.Second
+	cfg.Settings.WebhookBackoffMax = time.Minute
+
+	return NewSender(logging.GetLogger(), cfg, webhookRep).(*sender)
+}
+
+func Test_sender_lease(t *testing.T) {
+	s := newTestSender(nil)
+	s.Cfg.Settings.WebhookBatchSize = 10
+	s.Cfg.Settings.WebhookWorkers = 4
+
+	// 3 раунда отправки пачки и один про запас
+	assert.Equal(t, time.Second*4, s.lease())
+
+	s.Cfg.Settings.WebhookRequestTimeout = time.Second * 30
+	assert.Equal(t, time.Minute*2, s.lease(), "lease follows request timeout")
+}
@@ -0,0 +1,76 @@
+// Package apperr описывает виды ошибок предметной области,
+// по которым слой HTTP выбирает код ответа
+package apperr
+
+import "errors"
+
+// виды ошибок
+var (
+	ErrBadRequest        = errors.New("bad request")
+	ErrValidation        = errors.New("validation failed")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrForbidden         = errors.New("forbidden")
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrTooLarge          = errors.New("request too large")
+	ErrTooManyRequests   = errors.New("too many requests")
+	ErrNotImplemented    = errors.New("not implemented")
+)
+
+// Error ошибка определённого вида. errors.Is сравнивает её и с видом,
+// и с вложенной ошибкой, поэтому доменные переменные ошибок остаются
+// пригодными для errors.Is в вызывающем коде
+type Error struct {
+	kind error
+	msg  string
+	err  error
+}
+
+// New создаёт ошибку вида kind с текстом msg
+func New(kind error, msg string) error {
+	return &Error{kind: kind, msg: msg}
+}
+
+// Wrap относит err к виду kind, сохраняя текст и цепочку err
+func Wrap(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{kind: kind, msg: err.Error(), err: err}
+}
+
+func BadRequest(err error) error {
+	return Wrap(ErrBadRequest, err)
+}
+
+func Validation(err error) error {
+	return Wrap(ErrValidation, err)
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.kind
+}
+
+// Kind возвращает вид ошибки
+func (e *Error) Kind() error {
+	return e.kind
+}
+
+// KindOf возвращает вид внешней ошибки из цепочки err или nil.
+// Внешняя обёртка важнее вложенной: обработчик может переопределить вид
+func KindOf(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.kind
+	}
+	return nil
+}
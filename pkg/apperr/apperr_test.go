@@ -0,0 +1,37 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	assert := assert.New(t)
+
+	errOrderNotFound := New(ErrNotFound, "order not found")
+	wrapped := fmt.Errorf("find order: %w", errOrderNotFound)
+
+	assert.ErrorIs(wrapped, errOrderNotFound)
+	assert.ErrorIs(wrapped, ErrNotFound)
+	assert.NotErrorIs(wrapped, ErrConflict)
+	assert.Equal(ErrNotFound, KindOf(wrapped))
+	assert.Equal("find order: order not found", wrapped.Error())
+
+	assert.Nil(KindOf(errors.New("plain")))
+	assert.Nil(Wrap(ErrConflict, nil))
+}
+
+func TestKindOf_outermost(t *testing.T) {
+	assert := assert.New(t)
+
+	errWeak := New(ErrValidation, "weak password")
+	err := BadRequest(errWeak)
+
+	assert.Equal(ErrBadRequest, KindOf(err))
+	assert.ErrorIs(err, errWeak)
+	assert.ErrorIs(err, ErrValidation)
+	assert.Equal("weak password", err.Error())
+}
@@ -0,0 +1,129 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount - сумма в баллах с точностью до сотых, хранится в целых сотых долях
+type Amount int64
+
+const scale = 100
+
+var (
+	ErrInvalidAmount = errors.New("invalid amount")
+	ErrTooPrecise    = errors.New("amount has more than two fractional digits")
+	ErrOutOfRange    = errors.New("amount is out of range")
+)
+
+// Parse разбирает десятичную запись суммы, например "500", "-42.5" или "0.01"
+func Parse(s string) (Amount, error) {
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasFrac := strings.Cut(s, ".")
+	if len(intPart) < 1 || !isDigits(intPart) || (hasFrac && (len(fracPart) < 1 || !isDigits(fracPart))) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(fracPart) > 2 {
+		return 0, fmt.Errorf("%w: %q", ErrTooPrecise, s)
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	cents := int64(0)
+	if len(fracPart) > 0 {
+		cents, _ = strconv.ParseInt((fracPart + "0")[:2], 10, 64)
+	}
+
+	if units > (math.MaxInt64-cents)/scale {
+		return 0, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+	}
+
+	a := Amount(units*scale + cents)
+	if neg {
+		a = -a
+	}
+	return a, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String возвращает десятичную запись суммы без лишних нулей: "500.5", "42"
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	units, cents := v/scale, v%scale
+	if cents == 0 {
+		return fmt.Sprintf("%s%d", sign, units)
+	}
+	return strings.TrimRight(fmt.Sprintf("%s%d.%02d", sign, units, cents), "0")
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	v, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value сохраняет сумму в колонку NUMERIC
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan читает сумму из колонки NUMERIC
+func (a *Amount) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		*a = Amount(v * scale)
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	r.Mul(r, big.NewRat(scale, 1))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return fmt.Errorf("%w: %q", ErrTooPrecise, s)
+	}
+	*a = Amount(r.Num().Int64())
+	return nil
+}
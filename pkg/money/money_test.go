@@ -0,0 +1,104 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Amount
+		wantErr bool
+	}{
+		{name: "integer", s: "500", want: 50000},
+		{name: "one fractional digit", s: "500.5", want: 50050},
+		{name: "two fractional digits", s: "0.01", want: 1},
+		{name: "negative", s: "-42.25", want: -4225},
+		{name: "three fractional digits", s: "1.001", wantErr: true},
+		{name: "empty fraction", s: "1.", wantErr: true},
+		{name: "exponent", s: "1e2", wantErr: true},
+		{name: "not a number", s: "abc", wantErr: true},
+		{name: "empty", s: "", wantErr: true},
+		{name: "max", s: "92233720368547758.07", want: math.MaxInt64},
+		{name: "negative max", s: "-92233720368547758.07", want: -math.MaxInt64},
+		{name: "just past max", s: "92233720368547758.08", wantErr: true},
+		{name: "units past max", s: "922337203685477581", wantErr: true},
+		{name: "wraps to small amount", s: "184467440737095517.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, err := Parse(tt.s)
+			assert.Equal(tt.wantErr, err != nil)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestAmount_String(t *testing.T) {
+	tests := []struct {
+		a    Amount
+		want string
+	}{
+		{a: 50000, want: "500"},
+		{a: 50050, want: "500.5"},
+		{a: 1, want: "0.01"},
+		{a: -4225, want: "-42.25"},
+		{a: 0, want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.a.String())
+		})
+	}
+}
+
+func TestAmount_JSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var v struct {
+		Sum Amount `json:"sum"`
+	}
+	assert.NoError(json.Unmarshal([]byte(`{"sum":751.5}`), &v))
+	assert.Equal(Amount(75150), v.Sum)
+
+	data, err := json.Marshal(v)
+	assert.NoError(err)
+	assert.Equal(`{"sum":751.5}`, string(data))
+
+	assert.Error(json.Unmarshal([]byte(`{"sum":751.555}`), &v))
+	assert.Error(json.Unmarshal([]byte(`{"sum":"751"}`), &v))
+}
+
+func TestAmount_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Amount
+		wantErr bool
+	}{
+		{name: "numeric text", src: "500.50", want: 50050},
+		{name: "numeric exponent", src: "50050e-2", want: 50050},
+		{name: "bytes", src: []byte("42"), want: 4200},
+		{name: "int64", src: int64(7), want: 700},
+		{name: "null", src: nil, want: 0},
+		{name: "too precise", src: "1.005", wantErr: true},
+		{name: "unsupported type", src: 1.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			var a Amount
+			err := a.Scan(tt.src)
+			assert.Equal(tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(tt.want, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package page разбирает общие параметры постраничной выдачи списков:
+// интервал from/to, курсор after и размер страницы limit
+package page
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickzhog/gophermart/pkg/apperr"
+)
+
+const MaxLimit = 1000
+
+var ErrBadFilter = apperr.New(apperr.ErrBadRequest, "bad filter")
+
+// Cursor указывает на последнюю запись страницы по времени сортировки и ID,
+// следующая страница начинается после неё
+type Cursor struct {
+	At time.Time
+	ID string
+}
+
+func (c Cursor) String() string {
+	raw := c.At.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: cursor: %s", ErrBadFilter, err.Error())
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return Cursor{}, fmt.Errorf("%w: cursor", ErrBadFilter)
+	}
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: cursor: %s", ErrBadFilter, err.Error())
+	}
+	return Cursor{At: at, ID: id}, nil
+}
+
+// Params ограничивает выборку по времени и задаёт страницу, нулевые поля не ограничивают
+type Params struct {
+	From  time.Time // включительно
+	To    time.Time // не включительно
+	After *Cursor
+	Limit int
+}
+
+// ParseParams разбирает параметры запроса from, to, after и limit
+func ParseParams(q url.Values) (Params, error) {
+	var (
+		p   Params
+		err error
+	)
+
+	if v := q.Get("from"); v != "" {
+		if p.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return Params{}, fmt.Errorf("%w: from: %s", ErrBadFilter, err.Error())
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if p.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return Params{}, fmt.Errorf("%w: to: %s", ErrBadFilter, err.Error())
+		}
+	}
+	if !p.From.IsZero() && !p.To.IsZero() && !p.From.Before(p.To) {
+		return Params{}, fmt.Errorf("%w: from must be before to", ErrBadFilter)
+	}
+
+	if v := q.Get("after"); v != "" {
+		c, err := ParseCursor(v)
+		if err != nil {
+			return Params{}, err
+		}
+		p.After = &c
+	}
+
+	if p.Limit, err = ParseLimit(q.Get("limit"), 0); err != nil {
+		return Params{}, err
+	}
+
+	return p, nil
+}
+
+// ParseLimit разбирает размер страницы от 1 до MaxLimit, пустое значение даёт def
+func ParseLimit(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > MaxLimit {
+		return 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrBadFilter, MaxLimit)
+	}
+	return limit, nil
+}
@@ -0,0 +1,101 @@
+package page
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	assert := assert.New(t)
+	c := Cursor{At: time.Date(2022, 10, 1, 12, 0, 0, 123456000, time.UTC), ID: "5880182"}
+
+	got, err := ParseCursor(c.String())
+	assert.NoError(err)
+	assert.Equal(c, got)
+
+	_, err = ParseCursor("not a cursor")
+	assert.ErrorIs(err, ErrBadFilter)
+}
+
+func TestParseParams(t *testing.T) {
+	cursor := Cursor{At: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC), ID: "2377225624"}
+	tests := []struct {
+		name    string
+		query   string
+		want    Params
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  Params{},
+		},
+		{
+			name:  "all parameters",
+			query: "from=2022-10-01T00:00:00Z&to=2022-10-02T00:00:00Z&limit=10&after=" + cursor.String(),
+			want: Params{
+				From:  time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+				To:    time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC),
+				After: &cursor,
+				Limit: 10,
+			},
+		},
+		{
+			name:    "bad date",
+			query:   "to=tomorrow",
+			wantErr: true,
+		},
+		{
+			name:    "empty date range",
+			query:   "from=2022-10-02T00:00:00Z&to=2022-10-02T00:00:00Z",
+			wantErr: true,
+		},
+		{
+			name:    "bad cursor",
+			query:   "after=bm9wZQ",
+			wantErr: true,
+		},
+		{
+			name:    "zero limit",
+			query:   "limit=0",
+			wantErr: true,
+		},
+		{
+			name:    "limit too big",
+			query:   "limit=100000",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			q, _ := url.ParseQuery(tt.query)
+
+			got, err := ParseParams(q)
+			assert.Equal(tt.wantErr, err != nil)
+			if err != nil {
+				assert.ErrorIs(err, ErrBadFilter)
+				return
+			}
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	got, err := ParseLimit("", 100)
+	assert.NoError(err)
+	assert.Equal(100, got)
+
+	got, err = ParseLimit("5", 100)
+	assert.NoError(err)
+	assert.Equal(5, got)
+
+	_, err = ParseLimit("abc", 100)
+	assert.ErrorIs(err, ErrBadFilter)
+}
@@ -9,12 +9,23 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// коды ошибок PostgreSQL (SQLSTATE)
+const (
+	UniqueViolation           = "23505"
+	CheckViolation            = "23514"
+	ForeignKeyViolation       = "23503"
+	InvalidTextRepresentation = "22P02"
+)
+
 type Client interface {
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	Begin(ctx context.Context) (pgx.Tx, error)
 	Ping(ctx context.Context) error
+	// Acquire берёт из пула отдельное соединение, например для LISTEN.
+	// Соединение учитывается в лимите пула, пока не будет возвращено Release
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
 }
 
 func NewConnection(ctx context.Context, maxAttempts int, connString string) (pool Client, err error) {